@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chuhlomin/gbfs-go"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+
+	tools "github.com/chuhlomin/gbfs-tools/pkg/gbfs"
+)
+
+// runEstimate prints estimated trip cost for system pricing plans:
+//
+//	cli estimate -system citibike_nyc -minutes 45 -km 8 [-plan 1]
+func runEstimate(args []string) error {
+	fs := flag.NewFlagSet("estimate", flag.ContinueOnError)
+	systemID := fs.String("system", "", "system ID, as in systems.csv")
+	planID := fs.String("plan", "", "pricing plan ID, all plans are estimated if omitted")
+	minutes := fs.Float64("minutes", 0, "trip duration in minutes")
+	km := fs.Float64("km", 0, "trip distance in kilometers")
+	systemsURL := fs.String("systems", gbfs.SystemsNABSA, "URL of systems.csv")
+
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrap(err, "parse arguments")
+	}
+	if *systemID == "" {
+		return fmt.Errorf("parse arguments: -system is required")
+	}
+
+	client := gbfs.NewClient("github.com/chuhlomin/gbfs-tools", 30*time.Second)
+
+	url, err := findPricingPlansURL(client, *systemsURL, *systemID)
+	if err != nil {
+		return err
+	}
+
+	plans, err := tools.LoadPricingPlans(url)
+	if err != nil {
+		return errors.Wrap(err, "load pricing plans")
+	}
+
+	if *planID != "" {
+		plan, err := tools.FindPricingPlan(plans, *planID)
+		if err != nil {
+			return errors.Wrap(err, "find plan")
+		}
+		plans = []tools.PricingPlan{*plan}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Plan", "Name", "Base", "Time", "Distance", "Total", "Currency"})
+	table.SetBorder(false)
+
+	for _, plan := range plans {
+		cost := tools.EstimateTripCost(plan, *minutes, *km)
+
+		var byTime, byDistance tools.Decimal
+		for _, s := range cost.Segments {
+			if s.Unit == "km" {
+				byDistance = byDistance.Add(s.Subtotal)
+			} else {
+				byTime = byTime.Add(s.Subtotal)
+			}
+		}
+
+		table.Append([]string{
+			string(plan.ID),
+			plan.Name,
+			cost.BasePrice.String(),
+			byTime.String(),
+			byDistance.String(),
+			cost.Total.String(),
+			strings.ToUpper(cost.Currency),
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+func findPricingPlansURL(client *gbfs.Client, systemsURL, systemID string) (string, error) {
+	systems, err := client.LoadSystems(systemsURL)
+	if err != nil {
+		return "", errors.Wrap(err, "load systems")
+	}
+
+	for _, s := range systems {
+		if s.ID != systemID {
+			continue
+		}
+
+		resp, err := client.LoadGBFS(s.AutoDiscoveryURL)
+		if err != nil {
+			return "", errors.Wrapf(err, "load GBFS %q", s.AutoDiscoveryURL)
+		}
+
+		feeds, err := resp.Data.GetDataFeeds("en")
+		if err != nil {
+			return "", errors.Wrapf(err, "get feeds of %q", systemID)
+		}
+
+		feed, err := feeds.GetFeed("system_pricing_plans")
+		if err != nil {
+			return "", fmt.Errorf("find system_pricing_plans feed of %q", systemID)
+		}
+
+		return feed.URL, nil
+	}
+
+	return "", fmt.Errorf("find system %q", systemID)
+}
@@ -13,7 +13,14 @@ import (
 )
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "estimate" {
+		err = runEstimate(os.Args[2:])
+	} else {
+		err = run()
+	}
+
+	if err != nil {
 		log.Printf("ERROR: Failed to %v", err)
 	}
 }
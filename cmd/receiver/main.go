@@ -0,0 +1,63 @@
+// Receiver is a local webhook endpoint for testing alerts notifications:
+// it verifies event signatures and timestamps and logs received events.
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/webhook"
+)
+
+type config struct {
+	Hostname string        `env:"HOSTNAME" envDefault:"127.0.0.1"`
+	Port     string        `env:"PORT" envDefault:"8083"`
+	Secret   string        `env:"WEBHOOK_SECRET,required"`
+	MaxAge   time.Duration `env:"WEBHOOK_MAX_AGE" envDefault:"5m"`
+}
+
+func main() {
+	log.Print("Starting...")
+	if err := run(); err != nil {
+		log.Fatalf("ERROR %v", err)
+	}
+}
+
+func run() error {
+	var c config
+	if err := env.Parse(&c); err != nil {
+		return errors.Wrap(err, "parse environment variables")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read body", 400)
+			return
+		}
+
+		timestamp := r.Header.Get(webhook.HeaderTimestamp)
+		if !webhook.Verify(c.Secret, timestamp, r.Header.Get(webhook.HeaderSignature), body) {
+			log.Printf("Invalid signature for delivery %s", r.Header.Get(webhook.HeaderID))
+			http.Error(w, "Invalid signature", 401)
+			return
+		}
+
+		if err := webhook.CheckTimestamp(timestamp, c.MaxAge); err != nil {
+			log.Printf("Rejected delivery %s: %v", r.Header.Get(webhook.HeaderID), err)
+			http.Error(w, "Stale timestamp", 401)
+			return
+		}
+
+		log.Printf("%s %s: %s", r.Header.Get(webhook.HeaderEvent), r.Header.Get(webhook.HeaderID), body)
+	})
+
+	bind := c.Hostname + ":" + c.Port
+	log.Printf("Listening on %v", bind)
+	return http.ListenAndServe(bind, nil)
+}
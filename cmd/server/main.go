@@ -2,42 +2,104 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 
 	g "github.com/chuhlomin/gbfs-go"
 
+	"github.com/chuhlomin/gbfs-tools/pkg/compress"
+	"github.com/chuhlomin/gbfs-tools/pkg/cors"
 	"github.com/chuhlomin/gbfs-tools/pkg/gbfs"
+	"github.com/chuhlomin/gbfs-tools/pkg/logging"
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
+	"github.com/chuhlomin/gbfs-tools/pkg/ratelimit"
 	"github.com/chuhlomin/gbfs-tools/pkg/redis"
+	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
 )
 
 type config struct {
 	Hostname     string `env:"HOSTNAME" envDefault:"127.0.0.1"`
 	Port         string `env:"PORT" envDefault:"8082"`
-	AllowOrigin  string `env:"CORS_ALLOW_ORIGIN" envDefault:"*"`
 	RedisNetwork string `env:"REDIS_NETWORK" envDefault:"tcp"`
 	RedisAddr    string `env:"REDIS_ADDR" envDefault:"redis:6379"`
 	RedisAuth    string `env:"REDIS_AUTH"`
+	MaxDepth     int    `env:"GRAPHQL_MAX_DEPTH" envDefault:"10"`
+	MaxCost      int    `env:"GRAPHQL_MAX_COST" envDefault:"10000"`
+
+	GraphiQL             bool          `env:"GRAPHIQL" envDefault:"true"`
+	Playground           bool          `env:"PLAYGROUND" envDefault:"true"`
+	Introspection        bool          `env:"GRAPHQL_INTROSPECTION" envDefault:"true"`
+	PersistedQueriesFile string        `env:"PERSISTED_QUERIES_FILE"`
+	PersistedQueriesOnly bool          `env:"PERSISTED_QUERIES_ONLY" envDefault:"false"`
+	CacheMaxAge          time.Duration `env:"GRAPHQL_CACHE_MAX_AGE" envDefault:"1m"`
+	MetricsOperations    []string      `env:"METRICS_GRAPHQL_OPERATIONS"`
+
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" envDefault:"10s"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" envDefault:"30s"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" envDefault:"30s"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" envDefault:"2m"`
+	ShutdownDelay     time.Duration `env:"SHUTDOWN_DELAY" envDefault:"5s"`
+	ShutdownTimeout   time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"20s"`
+	WriterMaxAge      time.Duration `env:"READY_WRITER_MAX_AGE" envDefault:"0"`
+
+	CORSAllowOrigins     []string      `env:"CORS_ALLOW_ORIGIN" envDefault:"*"`
+	CORSAllowMethods     []string      `env:"CORS_ALLOW_METHODS" envDefault:"GET,POST"`
+	CORSAllowHeaders     []string      `env:"CORS_ALLOW_HEADERS" envDefault:"Content-Type,Last-Event-ID,X-Request-ID,X-API-Key,traceparent,tracestate"`
+	CORSExposeHeaders    []string      `env:"CORS_EXPOSE_HEADERS" envDefault:"X-Request-ID,Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining,X-Quota-Limit,X-Quota-Remaining"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" envDefault:"false"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE" envDefault:"10m"`
+
+	APIKeysRequired bool    `env:"API_KEYS_REQUIRED" envDefault:"false"`
+	AnonRateLimit   float64 `env:"ANON_RATE_LIMIT" envDefault:"0"`
+	AnonRateBurst   int     `env:"ANON_RATE_BURST" envDefault:"0"`
+	AnonDailyQuota  int64   `env:"ANON_DAILY_QUOTA" envDefault:"0"`
+	ProxyHops       int     `env:"TRUSTED_PROXY_HOPS" envDefault:"0"`
+
+	WebhooksAllowPrivate bool `env:"WEBHOOKS_ALLOW_PRIVATE" envDefault:"false"`
+
+	LogLevel     string `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat    string `env:"LOG_FORMAT" envDefault:"json"`
+	OTLPEndpoint string `env:"OTLP_ENDPOINT"`
+	OTLPInsecure bool   `env:"OTLP_INSECURE" envDefault:"true"`
 }
 
 func main() {
-	log.Print("Stating...")
 	if err := run(); err != nil {
-		log.Fatalf("ERROR %v", err)
+		slog.Error("Failed to run", "error", err)
+		os.Exit(1)
 	}
 }
 
 func run() error {
-	log.Println("Parsing environment variables...")
 	var c config
 	if err := env.Parse(&c); err != nil {
 		return errors.Wrap(err, "parse environment variables")
 	}
 
+	if err := logging.Setup(c.LogLevel, c.LogFormat); err != nil {
+		return errors.Wrap(err, "setup logging")
+	}
+	slog.Info("Starting...")
+
+	if c.OTLPEndpoint != "" {
+		slog.Info("Exporting traces", "endpoint", c.OTLPEndpoint)
+		shutdown, err := tracing.Setup(context.Background(), "gbfs-server", c.OTLPEndpoint, c.OTLPInsecure)
+		if err != nil {
+			return errors.Wrap(err, "setup tracing")
+		}
+		defer shutdown(context.Background())
+	}
+
 	redisClient, err := redis.NewClient(
 		context.Background(),
 		c.RedisNetwork,
@@ -48,43 +110,170 @@ func run() error {
 		return errors.Wrap(err, "create Redis client")
 	}
 
-	log.Println("Loading systems in memory...")
+	slog.Info("Loading systems in memory...")
 	if err := redisClient.CacheAllSystems(); err != nil {
-		log.Printf("Failed to load systems: %v", err)
+		slog.Error("Failed to load systems", "error", err)
 	}
 
-	log.Println("Loading feeds in memory...")
+	slog.Info("Loading feeds in memory...")
 	if err := redisClient.CacheAllFeeds(); err != nil {
-		log.Printf("Failed to load feeds: %v", err)
+		slog.Error("Failed to load feeds", "error", err)
+	}
+
+	slog.Info("Loading coverages in memory...")
+	if err := redisClient.CacheAllCoverages(); err != nil {
+		slog.Error("Failed to load coverages", "error", err)
+	}
+
+	slog.Info("Loading systems statuses in memory...")
+	if err := redisClient.CacheAllSystemStatuses(); err != nil {
+		slog.Error("Failed to load systems statuses", "error", err)
 	}
 
 	gbfs.Client = g.NewClient("github.com/chuhlomin/gbfs-tools", 30*time.Second)
 	gbfs.RedisClient = redisClient
+	gbfs.MaxQueryDepth = c.MaxDepth
+	gbfs.MaxQueryCost = c.MaxCost
+	gbfs.CachePrivate = c.APIKeysRequired
+	gbfs.WebhooksAllowPrivate = c.WebhooksAllowPrivate
+
+	graphQLConfig := gbfs.GraphQLConfig{
+		GraphiQL:             c.GraphiQL,
+		Playground:           c.Playground,
+		Introspection:        c.Introspection,
+		PersistedQueriesOnly: c.PersistedQueriesOnly,
+		CacheMaxAge:          c.CacheMaxAge,
+	}
+	if c.PersistedQueriesFile != "" {
+		slog.Info("Loading persisted queries...")
+		graphQLConfig.PersistedQueries, err = gbfs.LoadPersistedQueries(c.PersistedQueriesFile)
+		if err != nil {
+			return errors.Wrap(err, "load persisted queries")
+		}
+	}
+	if c.PersistedQueriesOnly && len(graphQLConfig.PersistedQueries) == 0 {
+		return errors.New("PERSISTED_QUERIES_ONLY requires non-empty PERSISTED_QUERIES_FILE")
+	}
+	metrics.SetOperationNames(append(c.MetricsOperations, gbfs.OperationNames(graphQLConfig.PersistedQueries)...))
+
+	limiter := ratelimit.NewLimiter(redisClient, ratelimit.Config{
+		Required: c.APIKeysRequired,
+		Anonymous: structs.APIKey{
+			Rate:  c.AnonRateLimit,
+			Burst: c.AnonRateBurst,
+			Quota: c.AnonDailyQuota,
+		},
+		ProxyHops: c.ProxyHops,
+	})
+
+	corsConfig := cors.Config{
+		AllowedOrigins:   c.CORSAllowOrigins,
+		AllowedMethods:   c.CORSAllowMethods,
+		AllowedHeaders:   c.CORSAllowHeaders,
+		ExposedHeaders:   c.CORSExposeHeaders,
+		AllowCredentials: c.CORSAllowCredentials,
+		MaxAge:           c.CORSMaxAge,
+	}
+	if err := corsConfig.Validate(); err != nil {
+		return errors.Wrap(err, "CORS config")
+	}
+
+	route := func(path string, h http.Handler) {
+		http.HandleFunc(path, withLogging(path, metrics.InstrumentHandler(path, cors.Handler(corsConfig, limiter.Handler(h)))))
+	}
 
 	http.HandleFunc("/", ok)
-	http.HandleFunc("/graphql", withLogging(withCORS(gbfs.HandlerGraphQL(), c.AllowOrigin)))
-	http.HandleFunc("/geojson", withLogging(withCORS(gbfs.HandlerGeoJSON(), c.AllowOrigin)))
+	http.Handle("/healthz", gbfs.HandlerHealth())
+	http.Handle("/readyz", gbfs.HandlerReady(c.WriterMaxAge))
+	http.Handle("/metrics", metrics.Handler())
+	route("/graphql", compress.Handler(gbfs.HandlerGraphQL(graphQLConfig)))
+	route("/geojson", compress.Handler(gbfs.HandlerGeoJSON()))
+	route("/systems/at", compress.Handler(gbfs.HandlerSystemsAt()))
+	route("/stream/stations", gbfs.HandlerStationsStream())
+	route("/usage", limiter.HandlerUsage())
+
+	srv := &http.Server{
+		Addr:              c.Hostname + ":" + c.Port,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+	}
+	srv.RegisterOnShutdown(gbfs.Shutdown)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "address", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
 
-	bind := c.Hostname + ":" + c.Port
-	log.Printf("Listening on %v", bind)
-	return http.ListenAndServe(bind, nil)
+	select {
+	case err := <-errs:
+		return errors.Wrap(err, "listen")
+	case <-ctx.Done():
+	}
+
+	// keep serving until load balancer notices failing readiness
+	slog.Info("Draining...", "delay", c.ShutdownDelay)
+	gbfs.Drain()
+	time.Sleep(c.ShutdownDelay)
+
+	slog.Info("Shutting down...", "timeout", c.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return errors.Wrap(err, "shutdown")
+	}
+	slog.Info("Stopped")
+	return nil
 }
 
 func ok(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("OK"))
 }
 
-func withCORS(next http.Handler, allowOrigin string) http.HandlerFunc {
+// withLogging assigns ID to the request, starts its span
+// (continuing trace from traceparent header) and logs the request
+func withLogging(route string, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Access-Control-Allow-Origin", allowOrigin)
-		w.Header().Add("Access-Control-Allow-Headers", "Content-Type")
-		next.ServeHTTP(w, r)
+		id := logging.NewRequestID(r.Header.Get(logging.RequestIDHeader))
+		w.Header().Set(logging.RequestIDHeader, id)
+
+		ctx := logging.WithRequestID(tracing.Extract(r.Context(), r), id)
+		ctx, span := tracing.Start(
+			ctx,
+			r.Method+" "+route,
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("http.request_id", id),
+		)
+		defer span.End()
+
+		slog.InfoContext(
+			ctx,
+			"Request",
+			"method", r.Method,
+			"url", redactURL(r.URL),
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
-func withLogging(next http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s %s", r.Method, r.URL, r.RemoteAddr, r.UserAgent())
-		next.ServeHTTP(w, r)
+// redactURL returns URL with API key query parameter hidden, so it is not logged
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	if !query.Has(ratelimit.APIKeyParam) {
+		return u.String()
 	}
+
+	query.Set(ratelimit.APIKeyParam, "REDACTED")
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
 }
@@ -2,42 +2,80 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/caarlos0/env/v6"
 
 	"github.com/chuhlomin/gbfs-go"
+	"github.com/chuhlomin/gbfs-tools/pkg/alerts"
+	"github.com/chuhlomin/gbfs-tools/pkg/geo"
+	"github.com/chuhlomin/gbfs-tools/pkg/logging"
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
 	"github.com/chuhlomin/gbfs-tools/pkg/redis"
+	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+	"github.com/chuhlomin/gbfs-tools/pkg/webhook"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type config struct {
-	SystemsURL   string        `env:"SYSTEMS_CSV_URL" envDefault:"https://raw.githubusercontent.com/NABSA/gbfs/master/systems.csv"`
-	WriteSystems bool          `env:"WRITE_SYSTEMS" envDefault:"true"`
-	WriteFeeds   bool          `env:"WRITE_FEEDS" envDefault:"true"`
-	RedisNetwork string        `env:"REDIS_NETWORK" envDefault:"tcp"`
-	RedisAddr    string        `env:"REDIS_ADDR" envDefault:"redis:6379"`
-	RedisAuth    string        `env:"REDIS_AUTH"`
-	FeedsDelay   time.Duration `env:"FEEDS_DELAY" envDefault:"2s"`
+	SystemsURL    string        `env:"SYSTEMS_CSV_URL" envDefault:"https://raw.githubusercontent.com/NABSA/gbfs/master/systems.csv"`
+	WriteSystems  bool          `env:"WRITE_SYSTEMS" envDefault:"true"`
+	WriteFeeds    bool          `env:"WRITE_FEEDS" envDefault:"true"`
+	WriteCoverage bool          `env:"WRITE_COVERAGE" envDefault:"true"`
+	RedisNetwork  string        `env:"REDIS_NETWORK" envDefault:"tcp"`
+	RedisAddr     string        `env:"REDIS_ADDR" envDefault:"redis:6379"`
+	RedisAuth     string        `env:"REDIS_AUTH"`
+	FeedsDelay    time.Duration `env:"FEEDS_DELAY" envDefault:"2s"`
+	MetricsAddr   string        `env:"METRICS_ADDR"`
+
+	WatchAlerts     bool          `env:"WATCH_ALERTS" envDefault:"false"`
+	AlertsInterval  time.Duration `env:"ALERTS_INTERVAL" envDefault:"1m"`
+	WebhookAttempts int           `env:"WEBHOOK_ATTEMPTS" envDefault:"5"`
+	WebhookBackoff  time.Duration `env:"WEBHOOK_BACKOFF" envDefault:"2s"`
+	WebhookTimeout  time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`
+	WebhookPrivate  bool          `env:"WEBHOOKS_ALLOW_PRIVATE" envDefault:"false"`
+
+	LogLevel     string `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat    string `env:"LOG_FORMAT" envDefault:"json"`
+	OTLPEndpoint string `env:"OTLP_ENDPOINT"`
+	OTLPInsecure bool   `env:"OTLP_INSECURE" envDefault:"true"`
 }
 
 func main() {
-	log.Print("Starting...")
 	if err := run(); err != nil {
-		log.Fatalf("ERROR %v", err)
+		slog.Error("Failed to run", "error", err)
+		os.Exit(1)
 	}
-	log.Print("Finished")
+	slog.Info("Finished")
 }
 
 func run() error {
-	log.Println("Parsing environment variables...")
 	var c config
 	if err := env.Parse(&c); err != nil {
 		return errors.Wrap(err, "parse environment variables")
 	}
 
+	if err := logging.Setup(c.LogLevel, c.LogFormat); err != nil {
+		return errors.Wrap(err, "setup logging")
+	}
+	slog.Info("Starting...")
+
+	if c.OTLPEndpoint != "" {
+		slog.Info("Exporting traces", "endpoint", c.OTLPEndpoint)
+		shutdown, err := tracing.Setup(context.Background(), "gbfs-writer", c.OTLPEndpoint, c.OTLPInsecure)
+		if err != nil {
+			return errors.Wrap(err, "setup tracing")
+		}
+		defer shutdown(context.Background())
+	}
+
 	redisClient, err := redis.NewClient(
 		context.Background(),
 		c.RedisNetwork,
@@ -48,6 +86,10 @@ func run() error {
 		return errors.Wrap(err, "create Redis client")
 	}
 
+	if c.MetricsAddr != "" {
+		go serveMetrics(c.MetricsAddr)
+	}
+
 	gbfsClient := gbfs.NewClient("github.com/chuhlomin/gbfs-tools/writer", 30*time.Second)
 
 	resp, err := http.Get(c.SystemsURL)
@@ -61,51 +103,233 @@ func run() error {
 		return errors.Wrap(err, "parse systems CSV")
 	}
 
-	log.Print("Writing systems...")
+	slog.Info("Writing systems...")
 	if err := writeSystems(systems, redisClient); err != nil {
 		return errors.Wrap(err, "write systems")
 	}
 
 	if c.WriteFeeds {
-		log.Print("Writing feeds...")
-		if err := writeFeeds(systems, redisClient, gbfsClient, c.FeedsDelay); err != nil {
+		slog.Info("Writing feeds...")
+		if err := writeFeeds(context.Background(), systems, redisClient, gbfsClient, c.FeedsDelay, c.WriteCoverage); err != nil {
 			return errors.Wrap(err, "write feeds")
 		}
 	}
 
+	// server readiness checks age of the last run
+	if err := redisClient.WriteLastRun(time.Now()); err != nil {
+		return errors.Wrap(err, "write last run")
+	}
+
+	if c.WatchAlerts {
+		slog.Info("Watching alerts...", "interval", c.AlertsInterval)
+		watchAlerts(c, redisClient, gbfsClient)
+	}
+
 	return nil
 }
 
+// serveMetrics serves Prometheus metrics while writer is running
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	slog.Info("Serving metrics", "address", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("Failed to serve metrics", "error", err)
+	}
+}
+
+// watchAlerts delivers system alerts changes to subscribers
+// until SIGINT or SIGTERM is received
+func watchAlerts(c config, redisClient *redis.Client, gbfsClient *gbfs.Client) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sender := webhook.NewSender(
+		"github.com/chuhlomin/gbfs-tools/writer",
+		c.WebhookTimeout,
+		c.WebhookAttempts,
+		c.WebhookBackoff,
+		c.WebhookPrivate,
+	)
+
+	alerts.NewWatcher(redisClient, gbfsClient, sender, c.AlertsInterval).Run(ctx)
+}
+
 func writeSystems(systems []gbfs.System, redisClient *redis.Client) error {
+	ids := make([]string, 0, len(systems))
 	for _, system := range systems {
 		if err := redisClient.WriteSystem(system); err != nil {
 			return errors.Wrap(err, system.ID)
 		}
+		ids = append(ids, system.ID)
+	}
+
+	if err := redisClient.DisableSystemsExcept(ids); err != nil {
+		return errors.Wrap(err, "disable systems removed from systems.csv")
 	}
 
 	return nil
 }
 
 func writeFeeds(
+	ctx context.Context,
 	systems []gbfs.System,
 	redisClient *redis.Client,
 	client *gbfs.Client,
 	delay time.Duration,
+	writeCoverage bool,
 ) error {
+	crawlStart := time.Now()
+
 	for _, system := range systems {
-		resp, err := client.LoadGBFS(system.AutoDiscoveryURL)
-		if err != nil {
-			log.Printf("Failed to load GBFS for %q by URL %q: %v", system.ID, system.URL, err)
-			continue
+		if err := writeSystemFeeds(ctx, system, redisClient, client, writeCoverage); err != nil {
+			return err
 		}
 
-		for lang, feeds := range resp.Data {
-			if err := redisClient.WriteFeeds(system.ID, lang, feeds.Feeds); err != nil {
-				return errors.Wrapf(err, "%s %s", system.ID, lang)
-			}
+		time.Sleep(delay)
+	}
+
+	metrics.ObserveCrawl(time.Since(crawlStart))
+	return nil
+}
+
+// writeSystemFeeds writes feeds, status and coverage of the system
+func writeSystemFeeds(
+	ctx context.Context,
+	system gbfs.System,
+	redisClient *redis.Client,
+	client *gbfs.Client,
+	writeCoverage bool,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "writer system", attribute.String("gbfs.system_id", system.ID))
+	defer func() { tracing.End(span, err) }()
+
+	redisClient = redisClient.WithContext(ctx)
+	status := structs.SystemStatus{CheckedAt: time.Now()}
+
+	done := tracing.StartFetch(ctx, system.ID, "gbfs", system.AutoDiscoveryURL)
+	resp, err := client.LoadGBFS(system.AutoDiscoveryURL)
+	done(err)
+	if err != nil {
+		metrics.CrawlFailure("gbfs")
+
+		status.Health = structs.SystemHealthDown
+		status.Error = err.Error()
+		if err := redisClient.WriteSystemStatus(system.ID, status); err != nil {
+			return errors.Wrapf(err, "%s status", system.ID)
 		}
+		return nil
+	}
 
-		time.Sleep(delay)
+	for lang, feeds := range resp.Data {
+		if err := redisClient.WriteFeeds(system.ID, lang, feeds.Feeds); err != nil {
+			return errors.Wrapf(err, "%s %s", system.ID, lang)
+		}
 	}
+
+	status.Version = resp.Version
+	if status.Version == "" { // version field was added in v1.1
+		status.Version = "1.0"
+	}
+
+	status.Health = structs.SystemHealthOK
+	positions, err := loadPositions(ctx, client, system.ID, resp.Data)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load positions", "system", system.ID, "error", err)
+		metrics.CrawlFailure("positions")
+		status.Health = structs.SystemHealthDegraded
+		status.Error = err.Error()
+	}
+	status.StationsCount = positions.stationsCount
+
+	if err := redisClient.WriteSystemStatus(system.ID, status); err != nil {
+		return errors.Wrapf(err, "%s status", system.ID)
+	}
+
+	if writeCoverage && err == nil {
+		if coverage := computeCoverage(positions.points); coverage != nil {
+			if err := redisClient.WriteCoverage(system.ID, *coverage); err != nil {
+				return errors.Wrapf(err, "%s coverage", system.ID)
+			}
+		}
+	}
+
 	return nil
 }
+
+// positions are locations of system stations and free vehicles
+type positions struct {
+	points        [][]float64
+	stationsCount int
+}
+
+// loadPositions loads positions of stations and free vehicles
+func loadPositions(ctx context.Context, client *gbfs.Client, systemID string, data gbfs.LanguageFeeds) (positions, error) {
+	var result positions
+
+	feeds, err := data.GetDataFeeds("en")
+	if err != nil {
+		return result, err
+	}
+
+	if feed, err := feeds.GetFeed("station_information"); err == nil {
+		done := tracing.StartFetch(ctx, systemID, "station_information", feed.URL)
+		si, err := client.LoadStationInformation(feed.URL)
+		done(err)
+		if err != nil {
+			return result, errors.Wrapf(err, "load station information %q", feed.URL)
+		}
+		for _, station := range si.Data.Stations {
+			result.points = append(result.points, []float64{station.Lon, station.Lat})
+		}
+		result.stationsCount = len(si.Data.Stations)
+	}
+
+	if feed, err := feeds.GetFeed("free_bike_status"); err == nil {
+		done := tracing.StartFetch(ctx, systemID, "free_bike_status", feed.URL)
+		fbs, err := client.LoadFreeBikeStatus(feed.URL)
+		done(err)
+		if err != nil {
+			return result, errors.Wrapf(err, "load free bike status %q", feed.URL)
+		}
+		for _, bike := range fbs.Data.Bikes {
+			result.points = append(result.points, []float64{bike.Lon, bike.Lat})
+		}
+	}
+
+	return result, nil
+}
+
+// computeCoverage builds system service area
+// from positions of stations and free vehicles
+func computeCoverage(points [][]float64) *structs.Coverage {
+	points = validPoints(points)
+	if len(points) == 0 {
+		return nil
+	}
+
+	hull := geo.ConvexHull(points)
+
+	return &structs.Coverage{
+		BBox:   geo.Bounds(points),
+		Center: geo.Centroid(hull),
+		Hull:   hull,
+	}
+}
+
+// validPoints skips positions some systems report
+// for stations that are not installed yet, like 0,0
+func validPoints(points [][]float64) [][]float64 {
+	var result [][]float64
+	for _, p := range points {
+		if p[0] == 0 && p[1] == 0 {
+			continue
+		}
+		if p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
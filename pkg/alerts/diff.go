@@ -0,0 +1,52 @@
+package alerts
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/webhook"
+)
+
+// Change represents single alert change between two snapshots
+type Change struct {
+	Type  webhook.EventType
+	Alert webhook.Alert
+}
+
+// Diff compares current alerts with previously seen ones (mapped by alert ID).
+// Alert is considered updated when its last_updated changes,
+// or, for feeds not providing last_updated, when any of its fields changes.
+func Diff(prev map[string]webhook.Alert, current []webhook.Alert) []Change {
+	var changes []Change
+
+	seen := map[string]struct{}{}
+	for _, alert := range current {
+		seen[alert.ID] = struct{}{}
+
+		old, ok := prev[alert.ID]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Type: webhook.EventAlertCreated, Alert: alert})
+		case alert.LastUpdated != 0 && old.LastUpdated != 0:
+			if alert.LastUpdated != old.LastUpdated {
+				changes = append(changes, Change{Type: webhook.EventAlertUpdated, Alert: alert})
+			}
+		case !reflect.DeepEqual(alert, old):
+			changes = append(changes, Change{Type: webhook.EventAlertUpdated, Alert: alert})
+		}
+	}
+
+	var resolved []string
+	for id := range prev {
+		if _, ok := seen[id]; !ok {
+			resolved = append(resolved, id)
+		}
+	}
+	sort.Strings(resolved)
+
+	for _, id := range resolved {
+		changes = append(changes, Change{Type: webhook.EventAlertResolved, Alert: prev[id]})
+	}
+
+	return changes
+}
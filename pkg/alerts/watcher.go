@@ -0,0 +1,162 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/redis"
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+	"github.com/chuhlomin/gbfs-tools/pkg/webhook"
+)
+
+// Watcher periodically loads system_alerts feeds of systems having subscribers
+// and delivers changes to them
+type Watcher struct {
+	redis    *redis.Client
+	client   *gbfs.Client
+	sender   *webhook.Sender
+	interval time.Duration
+}
+
+// NewWatcher creates new Watcher
+func NewWatcher(
+	redisClient *redis.Client,
+	client *gbfs.Client,
+	sender *webhook.Sender,
+	interval time.Duration,
+) *Watcher {
+	return &Watcher{
+		redis:    redisClient,
+		client:   client,
+		sender:   sender,
+		interval: interval,
+	}
+}
+
+// Run checks alerts every interval until context is done
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) check(ctx context.Context) {
+	systemIDs, err := w.redis.GetWebhooksSystems()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get systems with webhooks", "error", err)
+		return
+	}
+
+	for _, systemID := range systemIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := w.checkSystem(ctx, systemID); err != nil {
+			slog.WarnContext(ctx, "Failed to check alerts", "system", systemID, "error", err)
+		}
+	}
+}
+
+func (w *Watcher) checkSystem(ctx context.Context, systemID string) error {
+	url, err := w.redis.GetFeedURL(systemID, "system_alerts", "en")
+	if err != nil {
+		return errors.Wrap(err, "get system_alerts feed URL")
+	}
+	if url == "" {
+		return nil // system doesn't publish alerts
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "system_alerts", url)
+	resp, err := w.client.LoadSystemAlerts(url)
+	done(err)
+	if err != nil {
+		return errors.Wrapf(err, "load system alerts %q", url)
+	}
+
+	current := make([]webhook.Alert, 0, len(resp.Data.Alerts))
+	for _, a := range resp.Data.Alerts {
+		current = append(current, webhook.NewAlert(a))
+	}
+
+	state, seeded, err := w.redis.GetAlertsState(systemID)
+	if err != nil {
+		return err
+	}
+
+	prev := map[string]webhook.Alert{}
+	for id, val := range state {
+		var a webhook.Alert
+		if err := json.Unmarshal([]byte(val), &a); err != nil {
+			slog.WarnContext(ctx, "Failed to unmarshal stored alert", "system", systemID, "alert", id, "error", err)
+			continue
+		}
+		prev[id] = a
+	}
+
+	newState := map[string]string{}
+	for _, a := range current {
+		b, err := json.Marshal(a)
+		if err != nil {
+			return errors.Wrapf(err, "marshal alert %q", a.ID)
+		}
+		newState[a.ID] = string(b)
+	}
+
+	if !seeded {
+		// alerts existing before the first check are not delivered as created
+		slog.InfoContext(ctx, "Seeding alerts state", "system", systemID, "alerts", len(current))
+		return w.redis.WriteAlertsState(systemID, newState)
+	}
+
+	changes := Diff(prev, current)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	webhooks, err := w.redis.GetWebhooks(systemID)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, change := range changes {
+		event := webhook.NewEvent(change.Type, systemID, change.Alert)
+		slog.InfoContext(ctx, "Delivering alert change", "system", systemID, "alert", change.Alert.ID, "type", change.Type, "subscribers", len(webhooks))
+
+		for _, wh := range webhooks {
+			wg.Add(1)
+			go func(url, secret string) {
+				defer wg.Done()
+				if err := w.sender.Send(ctx, url, secret, event); err != nil {
+					slog.WarnContext(ctx, "Failed to deliver webhook", "system", systemID, "url", url, "error", err)
+				}
+			}(wh.URL, wh.Secret)
+		}
+	}
+	wg.Wait()
+
+	// state is saved after deliveries, so changes interrupted by shutdown are delivered on next start;
+	// deliveries failed after all attempts are not repeated
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return w.redis.WriteAlertsState(systemID, newState)
+}
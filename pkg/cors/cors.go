@@ -0,0 +1,224 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config configures cross-origin requests allowed by Handler
+type Config struct {
+	// AllowedOrigins are origins allowed to make requests:
+	// "*" allows any origin, "https://*.example.com" allows any subdomain
+	AllowedOrigins []string
+
+	// AllowedMethods are methods allowed in preflight requests
+	AllowedMethods []string
+
+	// AllowedHeaders are request headers allowed in preflight requests, "*" allows any
+	AllowedHeaders []string
+
+	// ExposedHeaders are response headers available to scripts
+	ExposedHeaders []string
+
+	// AllowCredentials allows requests with cookies and HTTP authentication
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache preflight responses
+	MaxAge time.Duration
+}
+
+// Validate checks that configuration doesn't allow credentials for any origin,
+// as that would let any site make authenticated requests
+func (c Config) Validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+	for _, origin := range c.AllowedOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return errors.New("credentials can not be allowed for any origin, list allowed origins explicitly")
+		}
+	}
+	return nil
+}
+
+type originPattern struct {
+	prefix, suffix string
+}
+
+func (p originPattern) match(origin string) bool {
+	if len(origin) <= len(p.prefix)+len(p.suffix) ||
+		!strings.HasPrefix(origin, p.prefix) ||
+		!strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+
+	// wildcard matches host labels only
+	middle := origin[len(p.prefix) : len(origin)-len(p.suffix)]
+	return !strings.ContainsAny(middle, "/:?#@")
+}
+
+type cors struct {
+	anyOrigin bool
+	origins   map[string]bool
+	patterns  []originPattern
+
+	anyHeader bool
+	methods   map[string]bool
+	headers   map[string]bool
+
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// Handler answers preflight requests and adds CORS headers to responses
+// for requests from allowed origins, other requests are passed as is.
+// Credentials are not allowed for any origin even if config allows them, see Config.Validate.
+func Handler(config Config, next http.Handler) http.HandlerFunc {
+	c := cors{
+		origins:          map[string]bool{},
+		methods:          map[string]bool{},
+		headers:          map[string]bool{},
+		allowMethods:     strings.Join(config.AllowedMethods, ", "),
+		allowHeaders:     strings.Join(config.AllowedHeaders, ", "),
+		exposeHeaders:    strings.Join(config.ExposedHeaders, ", "),
+		allowCredentials: config.AllowCredentials,
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			c.anyOrigin = true
+			c.allowCredentials = false
+		case strings.Count(origin, "*") == 1:
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			c.patterns = append(c.patterns, originPattern{prefix: prefix, suffix: suffix})
+		case origin != "":
+			c.origins[origin] = true
+		}
+	}
+
+	for _, method := range config.AllowedMethods {
+		c.methods[strings.ToUpper(strings.TrimSpace(method))] = true
+	}
+
+	for _, header := range config.AllowedHeaders {
+		header = strings.TrimSpace(header)
+		if header == "*" {
+			c.anyHeader = true
+		}
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// response depends on Origin, so it may not be cached for other origins
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			c.preflight(w, r, origin)
+			return
+		}
+
+		if origin != "" && c.originAllowed(origin) {
+			c.setOrigin(w, origin)
+			if c.exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", c.exposeHeaders)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// preflight answers preflight request, headers are not added if request is not allowed
+func (c cors) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	defer w.WriteHeader(http.StatusNoContent)
+
+	if origin == "" || !c.originAllowed(origin) {
+		return
+	}
+
+	if !c.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))] {
+		return
+	}
+
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if !c.headersAllowed(requested) {
+		return
+	}
+
+	c.setOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", c.allowMethods)
+	if requested != "" {
+		if c.anyHeader {
+			w.Header().Set("Access-Control-Allow-Headers", requested)
+		} else {
+			w.Header().Set("Access-Control-Allow-Headers", c.allowHeaders)
+		}
+	}
+	if c.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", c.maxAge)
+	}
+}
+
+func (c cors) originAllowed(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+
+	for _, p := range c.patterns {
+		if p.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// headersAllowed checks comma-separated list of requested headers
+func (c cors) headersAllowed(requested string) bool {
+	if c.anyHeader {
+		return true
+	}
+
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !c.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin allows response to be read by origin, any origin is allowed with "*"
+func (c cors) setOrigin(w http.ResponseWriter, origin string) {
+	if c.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
@@ -0,0 +1,157 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOriginPatternMatch(t *testing.T) {
+	p := originPattern{prefix: "https://", suffix: ".example.com"}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://example.com", false},
+		{"https://.example.com", false},
+		{"http://app.example.com", false},
+		{"https://app.example.com.evil.com", false},
+		{"https://evil.com/.example.com", false},
+		{"https://evil.com:443.example.com", false},
+		{"https://user@evil.com?.example.com", false},
+		{"https://evil.com#.example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := p.match(tt.origin); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestHeadersAllowed(t *testing.T) {
+	c := cors{headers: map[string]bool{
+		"Content-Type": true,
+		"X-Api-Key":    true,
+	}}
+
+	tests := []struct {
+		requested string
+		want      bool
+	}{
+		{"", true},
+		{"content-type", true},
+		{"Content-Type, x-api-key", true},
+		{"content-type,,x-api-key ", true},
+		{"content-type, authorization", false},
+		{"authorization", false},
+	}
+
+	for _, tt := range tests {
+		if got := c.headersAllowed(tt.requested); got != tt.want {
+			t.Errorf("headersAllowed(%q) = %v, want %v", tt.requested, got, tt.want)
+		}
+	}
+
+	wildcard := cors{anyHeader: true}
+	if !wildcard.headersAllowed("authorization, x-custom") {
+		t.Error("headersAllowed with any header = false, want true")
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	config := Config{
+		AllowedOrigins: []string{"https://example.com", "https://*.example.org"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-API-Key"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"allowed origin", "https://example.com", "POST", "content-type", true},
+		{"allowed pattern", "https://app.example.org", "GET", "", true},
+		{"unknown origin", "https://evil.com", "POST", "content-type", false},
+		{"method not allowed", "https://example.com", "DELETE", "", false},
+		{"header not allowed", "https://example.com", "POST", "authorization", false},
+		{"no origin", "", "POST", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("preflight request passed to next handler")
+			})
+
+			r := httptest.NewRequest(http.MethodOptions, "/graphql", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+
+			w := httptest.NewRecorder()
+			Handler(config, next).ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+
+			origin := w.Header().Get("Access-Control-Allow-Origin")
+			if tt.allowed {
+				if origin != tt.origin {
+					t.Errorf("Access-Control-Allow-Origin = %q, want %q", origin, tt.origin)
+				}
+				if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+					t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, "GET, POST")
+				}
+				if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+					t.Errorf("Access-Control-Max-Age = %q, want %q", got, "600")
+				}
+			} else if origin != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want none", origin)
+			}
+		})
+	}
+}
+
+func TestAnyOriginWithoutCredentials(t *testing.T) {
+	config := Config{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET"},
+		AllowCredentials: true,
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Error("Validate() = nil, want error for any origin with credentials")
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	w := httptest.NewRecorder()
+	Handler(config, next).ServeHTTP(w, r)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, "*")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want none", got)
+	}
+
+	config.AllowedOrigins = []string{"https://example.com"}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil for listed origins", err)
+	}
+}
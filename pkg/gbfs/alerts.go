@@ -0,0 +1,138 @@
+package gbfs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+type alert struct {
+	SystemID    string
+	ID          gbfs.ID
+	Type        gbfs.AlertType
+	Times       []alertTime
+	StationIDs  []string
+	RegionIDs   []string
+	URL         string
+	Summary     string
+	Description string
+	LastUpdated *time.Time
+}
+
+type alertTime struct {
+	Start time.Time
+	End   *time.Time
+}
+
+// getAlerts returns system alerts active at given time of given types,
+// zero time and empty types disable corresponding filter
+func getAlerts(ctx context.Context, systemID, lang string, activeAt time.Time, types []gbfs.AlertType) ([]alert, error) {
+	result := []alert{}
+
+	if !hasFeed(ctx, systemID, "system_alerts") {
+		return result, nil
+	}
+
+	url, err := feedURL(ctx, systemID, "system_alerts", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "system_alerts", url)
+	resp, err := Client.LoadSystemAlerts(url)
+	done(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load system alerts %q", url)
+	}
+
+	for _, a := range resp.Data.Alerts {
+		if !activeAt.IsZero() && !alertActiveAt(a, activeAt) {
+			continue
+		}
+		if len(types) > 0 && !containsAlertType(types, a.Type) {
+			continue
+		}
+		result = append(result, convertAlert(systemID, a))
+	}
+
+	return result, nil
+}
+
+// alertActiveAt reports whether any of alert time windows includes t,
+// alerts without time windows are always active
+func alertActiveAt(a gbfs.Alert, t time.Time) bool {
+	if len(a.Times) == 0 {
+		return true
+	}
+
+	for _, window := range a.Times {
+		if t.Before(window.Start.Time()) {
+			continue
+		}
+		if end := time.Time(window.End); !end.IsZero() && !t.Before(end) {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+func containsAlertType(types []gbfs.AlertType, t gbfs.AlertType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func convertAlert(systemID string, a gbfs.Alert) alert {
+	result := alert{
+		SystemID:    systemID,
+		ID:          a.ID,
+		Type:        a.Type,
+		Times:       []alertTime{},
+		StationIDs:  a.StationIDs,
+		RegionIDs:   a.RegionIDs,
+		URL:         a.URL,
+		Summary:     a.Summary,
+		Description: a.Description,
+	}
+
+	for _, window := range a.Times {
+		t := alertTime{Start: window.Start.Time()}
+		if !time.Time(window.End).IsZero() {
+			end := window.End.Time()
+			t.End = &end
+		}
+		result.Times = append(result.Times, t)
+	}
+
+	if !time.Time(a.LastUpdated).IsZero() {
+		lastUpdated := a.LastUpdated.Time()
+		result.LastUpdated = &lastUpdated
+	}
+
+	return result
+}
+
+func filterStationsByIDs(stations []station, ids []string) []station {
+	byID := make(map[gbfs.ID]station, len(stations))
+	for _, s := range stations {
+		byID[s.ID] = s
+	}
+
+	result := []station{}
+	for _, id := range ids {
+		if s, ok := byID[gbfs.ID(id)]; ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
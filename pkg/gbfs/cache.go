@@ -0,0 +1,133 @@
+package gbfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chuhlomin/gbfs-go"
+)
+
+// systemsMaxAge is max-age of responses built from systems list,
+// it changes only when writer runs
+const systemsMaxAge = 5 * time.Minute
+
+// CachePrivate makes cacheable responses private, so shared caches don't store them,
+// e.g. when API keys are required and responses depend on the key
+var CachePrivate bool
+
+// feedCache describes freshness of system feeds response is built from
+type feedCache struct {
+	lastUpdated time.Time
+	expires     time.Time
+	noCache     bool // one of feeds has to be always refreshed
+}
+
+// add takes feed header into account: response is as fresh as the most recent feed
+// and expires with the first expiring feed
+func (c *feedCache) add(h gbfs.Header) {
+	updated := h.LastUpdated.Time()
+	if updated.After(c.lastUpdated) {
+		c.lastUpdated = updated
+	}
+
+	if h.TTL <= 0 {
+		c.noCache = true
+		return
+	}
+
+	expires := updated.Add(time.Duration(h.TTL) * time.Second)
+	if c.expires.IsZero() || expires.Before(c.expires) {
+		c.expires = expires
+	}
+}
+
+// writeFeedCacheHeaders sets Cache-Control, ETag and Last-Modified of response built from feeds
+// for the request with lang, returns true if 304 was written for conditional request
+func writeFeedCacheHeaders(w http.ResponseWriter, r *http.Request, c feedCache, lang string) bool {
+	maxAge := time.Until(c.expires)
+	if c.noCache || c.expires.IsZero() || maxAge < time.Second {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", cacheControl(w, maxAge))
+	}
+
+	if c.lastUpdated.IsZero() {
+		return false
+	}
+
+	etag := hashETag(r.URL.RawQuery, lang, strconv.FormatInt(c.lastUpdated.Unix(), 10))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", c.lastUpdated.Format(http.TimeFormat))
+
+	return notModified(w, r, etag, c.lastUpdated)
+}
+
+// writeContentCacheHeaders sets Cache-Control with maxAge and ETag computed from response body,
+// returns true if 304 was written for conditional request
+func writeContentCacheHeaders(w http.ResponseWriter, r *http.Request, body []byte, maxAge time.Duration) bool {
+	w.Header().Set("Cache-Control", cacheControl(w, maxAge))
+
+	etag := hashETag(string(body))
+	w.Header().Set("ETag", etag)
+
+	return notModified(w, r, etag, time.Time{})
+}
+
+// cacheControl returns Cache-Control value of response cacheable for maxAge,
+// responses with rate limit headers of the client are private
+func cacheControl(w http.ResponseWriter, maxAge time.Duration) string {
+	scope := "public"
+	if CachePrivate || w.Header().Get("X-RateLimit-Limit") != "" || w.Header().Get("X-Quota-Limit") != "" {
+		scope = "private"
+	}
+	return fmt.Sprintf("%s, max-age=%d", scope, int(maxAge.Seconds()))
+}
+
+// hashETag returns weak ETag, so it stays valid for compressed responses
+func hashETag(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
+
+// notModified writes 304 if client has current version of response,
+// If-Modified-Since is checked only without If-None-Match
+func notModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatches(match, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches compares ETags from If-None-Match header with etag using weak comparison
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
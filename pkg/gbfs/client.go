@@ -0,0 +1,83 @@
+package gbfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const userAgent = "github.com/chuhlomin/gbfs-tools"
+
+// httpClient is used for feeds not supported by gbfs-go
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+func loadJSON(url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "create new request")
+	}
+
+	req.Header.Add("User-Agent", userAgent)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "send request")
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "read response body")
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.Wrap(err, "unmarshal JSON")
+	}
+
+	return nil
+}
+
+// feedURL returns URL of the system feed in language best matching requested one,
+// fails if system doesn't publish such feed
+// feedLanguage returns available language of the system feed best matching requested one,
+// requests for different languages resolved to the same feed can share its data
+func feedLanguage(ctx context.Context, systemID, feedName, lang string) (string, error) {
+	feeds, err := RedisClient.WithContext(ctx).GetFeeds(systemID)
+	if err != nil {
+		return "", errors.Wrapf(err, "get system %q feeds", systemID)
+	}
+
+	var available []string
+	for _, feed := range feeds {
+		if feed.Name == feedName {
+			available = append(available, feed.Language)
+		}
+	}
+
+	return negotiateLanguage(available, lang), nil
+}
+
+func feedURL(ctx context.Context, systemID, feedName, lang string) (string, error) {
+	lang, err := feedLanguage(ctx, systemID, feedName, lang)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := RedisClient.WithContext(ctx).GetFeedURL(systemID, feedName, lang)
+	if err != nil {
+		return "", errors.Wrapf(err, "get system %q %s feed URL", systemID, feedName)
+	}
+
+	if url == "" {
+		return "", fmt.Errorf("system %q has no %s feed", systemID, feedName)
+	}
+
+	return url, nil
+}
@@ -0,0 +1,117 @@
+package gbfs
+
+import (
+	"log/slog"
+	"math"
+
+	"github.com/liangyaopei/structmap"
+	gj "github.com/paulmach/go.geojson"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/geo"
+)
+
+// maxClusterZoom is the highest zoom level at which stations are clustered,
+// on closer zoom levels every station is returned as is
+const maxClusterZoom = 15
+
+// clusterRadius is the size of the grid cell in pixels
+const clusterRadius = 60
+
+type clusterProperties struct {
+	Cluster           bool `map:"cluster"`
+	PointCount        int  `map:"pointCount"`
+	Capacity          int  `map:"capacity"`
+	NumBikesAvailable uint `map:"numBikesAvailable"`
+	NumDocksAvailable uint `map:"numDocksAvailable"`
+}
+
+type cluster struct {
+	stations []gbfs.StationInformation
+	lonSum   float64
+	latSum   float64
+	props    clusterProperties
+}
+
+// clusterStations groups stations into grid cells of clusterRadius pixels
+// at given zoom level, summing up capacity and availability from status
+func clusterStations(
+	stations []gbfs.StationInformation,
+	status []gbfs.StationStatus,
+	zoom int,
+) []*cluster {
+	statusByID := make(map[gbfs.ID]gbfs.StationStatus, len(status))
+	for _, s := range status {
+		statusByID[s.ID] = s
+	}
+
+	cells := map[[2]int64]*cluster{}
+	var result []*cluster // keeps clusters in order of appearance
+
+	for _, station := range stations {
+		x, y := geo.Project(station.Lon, station.Lat, zoom)
+		key := [2]int64{
+			int64(math.Floor(x / clusterRadius)),
+			int64(math.Floor(y / clusterRadius)),
+		}
+
+		c, ok := cells[key]
+		if !ok {
+			c = &cluster{props: clusterProperties{Cluster: true}}
+			cells[key] = c
+			result = append(result, c)
+		}
+
+		c.stations = append(c.stations, station)
+		c.lonSum += station.Lon
+		c.latSum += station.Lat
+		c.props.PointCount++
+		c.props.Capacity += station.Capacity
+
+		if s, ok := statusByID[station.ID]; ok {
+			c.props.NumBikesAvailable += s.NumBikesAvailable
+			c.props.NumDocksAvailable += s.NumDocksAvailable
+		}
+	}
+
+	return result
+}
+
+func convertClustersToGeoJSON(clusters []*cluster) *gj.FeatureCollection {
+	fc := gj.NewFeatureCollection()
+	fc.Features = []*gj.Feature{}
+
+	for _, c := range clusters {
+		if len(c.stations) == 1 {
+			feature, err := convertStationToGeoJSON(c.stations[0])
+			if err != nil {
+				slog.Warn("Failed to convert station", "error", err)
+				continue
+			}
+			fc.Features = append(fc.Features, feature)
+			continue
+		}
+
+		m, err := structmap.StructToMap(&c.props, "map", "")
+		if err != nil {
+			slog.Warn("Failed to convert struct to map", "error", err)
+			continue
+		}
+
+		count := float64(len(c.stations))
+		feature := gj.Feature{
+			Geometry: &gj.Geometry{
+				Type: gj.GeometryPoint,
+				Point: []float64{
+					c.lonSum / count,
+					c.latSum / count,
+				},
+			},
+			Properties: m,
+		}
+		fc.Features = append(fc.Features, &feature)
+	}
+
+	return fc
+}
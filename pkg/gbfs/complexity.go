@@ -0,0 +1,259 @@
+package gbfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// MaxQueryDepth is the maximum nesting of fields in GraphQL query, 0 disables the limit
+var MaxQueryDepth = 10
+
+// MaxQueryCost is the maximum estimated cost of GraphQL query, 0 disables the limit
+var MaxQueryCost = 10000
+
+const (
+	// objectFieldCost is a cost of fields returning objects
+	// which are resolved from data already loaded or cached in memory
+	objectFieldCost = 1
+	// upstreamFieldCost is a cost of fields loading feeds from system operators
+	upstreamFieldCost = 50
+	// defaultListSize is assumed number of items in lists
+	// and connections requested without first or last argument
+	defaultListSize = 50
+)
+
+// fieldCosts are costs of fields by "Type.field", fields not listed here
+// cost 0 if they return scalars and objectFieldCost otherwise
+var fieldCosts = map[string]int{
+	"Query.node":             upstreamFieldCost,
+	"Query.estimateTripCost": upstreamFieldCost,
+	"Query.stationStatus":    upstreamFieldCost,
+	"Query.stations":         upstreamFieldCost,
+	"Query.vehicles":         upstreamFieldCost,
+	"Query.geofencingZones":  upstreamFieldCost,
+	"Query.pointRules":       upstreamFieldCost,
+
+	"System.alerts":       upstreamFieldCost,
+	"System.regions":      upstreamFieldCost,
+	"System.hours":        upstreamFieldCost,
+	"System.calendar":     upstreamFieldCost,
+	"System.isOpen":       upstreamFieldCost,
+	"System.vehicleTypes": upstreamFieldCost,
+	"System.pricingPlans": upstreamFieldCost,
+
+	"Subscription.stationStatusChanged": upstreamFieldCost,
+	"Subscription.vehiclesChanged":      upstreamFieldCost,
+}
+
+// complexityError is returned for queries exceeding limits
+type complexityError struct {
+	code    string
+	message string
+	value   int
+	max     int
+}
+
+func (e *complexityError) Error() string {
+	return e.message
+}
+
+// formatted returns GraphQL error with limit details in extensions
+func (e *complexityError) formatted() gqlerrors.FormattedError {
+	return gqlerrors.FormattedError{
+		Message: e.message,
+		Extensions: map[string]interface{}{
+			"code":  e.code,
+			"value": e.value,
+			"max":   e.max,
+		},
+	}
+}
+
+// queryComplexity calculates depth and estimated cost of GraphQL operation
+type queryComplexity struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// checkQueryComplexity returns error if operation exceeds MaxQueryDepth or MaxQueryCost
+func checkQueryComplexity(schema graphql.Schema, doc *ast.Document, op *ast.OperationDefinition, variables map[string]interface{}) error {
+	if MaxQueryDepth <= 0 && MaxQueryCost <= 0 {
+		return nil
+	}
+
+	qc := queryComplexity{
+		schema:    schema,
+		fragments: documentFragments(doc),
+		variables: variables,
+	}
+
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	default:
+		root = schema.QueryType()
+	}
+	if root == nil {
+		return nil
+	}
+
+	depth, cost := qc.selectionSet(root, op.SelectionSet, 0, map[string]bool{})
+
+	if MaxQueryDepth > 0 && depth > MaxQueryDepth {
+		return &complexityError{
+			code:    "QUERY_TOO_DEEP",
+			message: fmt.Sprintf("query depth %d exceeds maximum allowed depth %d", depth, MaxQueryDepth),
+			value:   depth,
+			max:     MaxQueryDepth,
+		}
+	}
+	if MaxQueryCost > 0 && cost > MaxQueryCost {
+		return &complexityError{
+			code: "QUERY_TOO_COMPLEX",
+			message: fmt.Sprintf(
+				"query cost %d exceeds maximum allowed cost %d, "+
+					"request fewer items with first or last arguments or fewer fields loading system feeds",
+				cost,
+				MaxQueryCost,
+			),
+			value: cost,
+			max:   MaxQueryCost,
+		}
+	}
+
+	return nil
+}
+
+// selectionSet returns depth and cost of selections on parent type,
+// pageSize is first or last argument of enclosing connection field
+func (qc queryComplexity) selectionSet(
+	parent graphql.Type,
+	set *ast.SelectionSet,
+	pageSize int,
+	visited map[string]bool,
+) (depth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, c int
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			d, c = qc.field(parent, s, pageSize, visited)
+		case *ast.InlineFragment:
+			d, c = qc.selectionSet(qc.fragmentType(parent, s.TypeCondition), s.SelectionSet, pageSize, visited)
+		case *ast.FragmentSpread:
+			fragment, ok := qc.fragments[s.Name.Value]
+			if !ok || visited[s.Name.Value] {
+				continue
+			}
+			visited[s.Name.Value] = true
+			d, c = qc.selectionSet(qc.fragmentType(parent, fragment.TypeCondition), fragment.SelectionSet, pageSize, visited)
+			delete(visited, s.Name.Value)
+		}
+
+		if d > depth {
+			depth = d
+		}
+		cost += c
+	}
+
+	return depth, cost
+}
+
+func (qc queryComplexity) field(parent graphql.Type, field *ast.Field, pageSize int, visited map[string]bool) (depth, cost int) {
+	name := field.Name.Value
+	// introspection is limited separately
+	if strings.HasPrefix(name, "__") {
+		return 0, 0
+	}
+
+	def := fieldDefinition(parent, name)
+	if def == nil {
+		return 0, 0
+	}
+
+	// all named types implement graphql.Type
+	fieldType, _ := graphql.GetNamed(def.Type).(graphql.Type)
+
+	if c, ok := fieldCosts[parent.Name()+"."+name]; ok {
+		cost = c
+	} else if !graphql.IsLeafType(fieldType) {
+		cost = objectFieldCost
+	}
+
+	childPageSize := 0
+	if size, ok := qc.pageSize(field); ok {
+		childPageSize = size
+	}
+
+	multiplier := 1
+	if _, ok := graphql.GetNullable(def.Type).(*graphql.List); ok {
+		multiplier = defaultListSize
+		if childPageSize > 0 {
+			multiplier = childPageSize
+		} else if pageSize > 0 {
+			multiplier = pageSize
+		}
+		childPageSize = 0
+	}
+
+	childDepth, childCost := qc.selectionSet(fieldType, field.SelectionSet, childPageSize, visited)
+
+	return childDepth + 1, cost + multiplier*childCost
+}
+
+// pageSize returns value of first or last argument of connection field
+func (qc queryComplexity) pageSize(field *ast.Field) (int, bool) {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" && arg.Name.Value != "last" {
+			continue
+		}
+
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return n, true
+			}
+		case *ast.Variable:
+			switch n := qc.variables[v.Name.Value].(type) {
+			case float64:
+				return int(n), true
+			case int:
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (qc queryComplexity) fragmentType(parent graphql.Type, condition *ast.Named) graphql.Type {
+	if condition == nil {
+		return parent
+	}
+	if t := qc.schema.Type(condition.Name.Value); t != nil {
+		return t
+	}
+	return parent
+}
+
+func fieldDefinition(parent graphql.Type, name string) *graphql.FieldDefinition {
+	switch t := parent.(type) {
+	case *graphql.Object:
+		return t.Fields()[name]
+	case *graphql.Interface:
+		return t.Fields()[name]
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package gbfs
+
+import (
+	"log/slog"
+
+	"github.com/liangyaopei/structmap"
+	gj "github.com/paulmach/go.geojson"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+)
+
+type systemProperties struct {
+	ID          string `map:"id,omitempty"`
+	Name        string `map:"name,omitempty"`
+	CountryCode string `map:"countryCode,omitempty"`
+	Location    string `map:"location,omitempty"`
+}
+
+func convertSystemsToGeoJSON(
+	systems []*structs.System,
+	coverages map[string]*structs.Coverage,
+) *gj.FeatureCollection {
+	fc := gj.NewFeatureCollection()
+	fc.Features = []*gj.Feature{}
+
+	for _, system := range systems {
+		coverage, ok := coverages[system.ID]
+		if !ok || len(coverage.Hull) == 0 {
+			continue
+		}
+
+		props := systemProperties{
+			ID:          system.ID,
+			Name:        system.Name,
+			CountryCode: system.CountryCode,
+			Location:    system.Location,
+		}
+		m, err := structmap.StructToMap(&props, "map", "")
+		if err != nil {
+			slog.Warn("Failed to convert struct to map", "error", err)
+			continue
+		}
+
+		fc.Features = append(fc.Features, &gj.Feature{
+			BoundingBox: coverage.BBox[:],
+			Geometry:    coverageGeometry(coverage.Hull),
+			Properties:  m,
+		})
+	}
+
+	return fc
+}
+
+// coverageGeometry returns polygon for the hull,
+// systems with one or two stations are represented by point or line
+func coverageGeometry(hull [][]float64) *gj.Geometry {
+	switch len(hull) {
+	case 1:
+		return gj.NewPointGeometry(hull[0])
+	case 2:
+		return gj.NewLineStringGeometry(hull)
+	}
+
+	return gj.NewPolygonGeometry([][][]float64{hull})
+}
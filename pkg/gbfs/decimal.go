@@ -0,0 +1,129 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxDecimalScale is the maximum number of decimal places kept, the rest is rounded
+const maxDecimalScale = 9
+
+// Decimal is an exact amount of money, units × 10^-scale.
+// It keeps decimal places as they are written in the feed, e.g. "2.50", "0.125" or "300".
+type Decimal struct {
+	units int64
+	scale int
+}
+
+// ParseDecimal parses decimal number, comma is accepted as decimal separator
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.Replace(strings.TrimSpace(s), ",", ".", 1)
+
+	if strings.ContainsAny(s, "eE") {
+		// exponent notation, e.g. 1e-2, is converted to plain decimal
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("parse decimal %q: %v", s, err)
+		}
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, fmt.Errorf("parse decimal %q: no digits", s)
+	}
+
+	roundUp := false
+	if len(fracPart) > maxDecimalScale {
+		roundUp = fracPart[maxDecimalScale] >= '5'
+		fracPart = fracPart[:maxDecimalScale]
+	}
+
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("parse decimal %q: invalid character %q", s, c)
+		}
+	}
+
+	units, err := strconv.ParseInt("0"+intPart+fracPart, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("parse decimal %q: %v", s, err)
+	}
+	if roundUp {
+		units++
+	}
+	if negative {
+		units = -units
+	}
+
+	return Decimal{units: units, scale: len(fracPart)}, nil
+}
+
+// UnmarshalJSON parses decimal that GBFS versions encode either as number or string
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		*d = Decimal{}
+		return nil
+	}
+
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s) == "" {
+			*d = Decimal{}
+			return nil
+		}
+	}
+
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+
+	*d = v
+	return nil
+}
+
+// Add returns sum of decimals with the larger of their scales
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b := d.rescale(other.scale), other.rescale(d.scale)
+	return Decimal{units: a.units + b.units, scale: a.scale}
+}
+
+// Mul returns decimal multiplied by n
+func (d Decimal) Mul(n int64) Decimal {
+	return Decimal{units: d.units * n, scale: d.scale}
+}
+
+// String returns decimal with its decimal places, like "2.50" or "300"
+func (d Decimal) String() string {
+	s := strconv.FormatInt(d.units, 10)
+	if d.scale == 0 {
+		return s
+	}
+
+	sign := ""
+	if d.units < 0 {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= d.scale {
+		s = strings.Repeat("0", d.scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+}
+
+// rescale returns decimal with at least given number of decimal places
+func (d Decimal) rescale(scale int) Decimal {
+	for d.scale < scale {
+		d.units *= 10
+		d.scale++
+	}
+	return d
+}
@@ -0,0 +1,366 @@
+package gbfs
+
+// https://github.com/NABSA/gbfs/blob/master/gbfs.md#geofencing_zonesjson
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	gj "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/geo"
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+type geofencingZonesResponse struct {
+	gbfs.Header
+	Data geofencingZonesData `json:"data"`
+}
+
+type geofencingZonesData struct {
+	GeofencingZones geofencingZones `json:"geofencing_zones"`
+}
+
+type geofencingZones struct {
+	Type     string           `json:"type"`
+	Features []geofencingZone `json:"features"`
+}
+
+type geofencingZone struct {
+	Geometry   *gj.Geometry             `json:"geometry"`
+	Properties geofencingZoneProperties `json:"properties"`
+}
+
+type geofencingZoneProperties struct {
+	Name  string           `json:"name,omitempty"`
+	Start *gbfs.Timestamp  `json:"start,omitempty"`
+	End   *gbfs.Timestamp  `json:"end,omitempty"`
+	Rules []geofencingRule `json:"rules,omitempty"`
+}
+
+type geofencingRule struct {
+	VehicleTypeIDs     []gbfs.ID `json:"vehicle_type_id,omitempty"`
+	RideAllowed        bool      `json:"ride_allowed"`
+	RideThroughAllowed bool      `json:"ride_through_allowed"`
+	MaximumSpeedKph    *int      `json:"maximum_speed_kph,omitempty"`
+	StationParking     *bool     `json:"station_parking,omitempty"`
+}
+
+// pointRules describes what is allowed at a specific location
+type pointRules struct {
+	InZone             bool
+	Zones              []string
+	RideAllowed        bool
+	RideThroughAllowed bool
+	MaximumSpeedKph    *int
+	StationParking     *bool
+}
+
+// minZonesTTL limits how often geofencing zones of systems with small or zero ttl
+// are loaded again to check locations
+const minZonesTTL = time.Minute
+
+// zonesConcurrency is the maximum number of geofencing zones feeds loaded at once
+const zonesConcurrency = 8
+
+// cachedZones are system geofencing zones in default language
+// kept in memory until the feed expires
+type cachedZones struct {
+	zones   []geofencingZone
+	bbox    geo.BBox
+	expires time.Time
+}
+
+var (
+	zonesCache   = map[string]*cachedZones{}
+	zonesCacheMu sync.Mutex
+	zonesLoads   singleflight.Group // concurrent requests share one load per system
+)
+
+// systemZones returns cached geofencing zones of the system, loading them when expired,
+// nil is returned if system has no geofencing zones or they failed to load.
+// Callers check that system publishes geofencing_zones feed.
+func systemZones(ctx context.Context, systemID string) *cachedZones {
+	zonesCacheMu.Lock()
+	cached, ok := zonesCache[systemID]
+	zonesCacheMu.Unlock()
+
+	fresh := ok && time.Now().Before(cached.expires)
+	metrics.CacheLookup("geofencing_zones", fresh)
+	if fresh {
+		return cached.orNil()
+	}
+
+	// load is not canceled with the request that started it, as other requests may wait for it
+	v, _, _ := zonesLoads.Do(systemID, func() (interface{}, error) {
+		return loadSystemZones(context.WithoutCancel(ctx), systemID), nil
+	})
+
+	return v.(*cachedZones).orNil()
+}
+
+// systemsZones returns geofencing zones of systems mapped by system ID,
+// zones are loaded concurrently, at most zonesConcurrency at once
+func systemsZones(ctx context.Context, systemIDs []string) map[string]*cachedZones {
+	result := make(map[string]*cachedZones, len(systemIDs))
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, zonesConcurrency)
+	)
+	for _, systemID := range systemIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(systemID string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			zones := systemZones(ctx, systemID)
+
+			mu.Lock()
+			result[systemID] = zones
+			mu.Unlock()
+		}(systemID)
+	}
+	wg.Wait()
+
+	return result
+}
+
+// loadSystemZones loads geofencing zones and puts them in cache,
+// failures are cached too, so unavailable feed is not requested for every location
+func loadSystemZones(ctx context.Context, systemID string) *cachedZones {
+	cached := &cachedZones{expires: time.Now().Add(minZonesTTL)}
+
+	resp, err := loadGeofencingZones(ctx, systemID, "")
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get geofencing zones", "system", systemID, "error", err)
+	} else {
+		cached.zones = resp.Data.GeofencingZones.Features
+		cached.bbox = zonesBounds(cached.zones)
+		if ttl := time.Duration(resp.TTL) * time.Second; ttl > minZonesTTL {
+			cached.expires = time.Now().Add(ttl)
+		}
+	}
+
+	zonesCacheMu.Lock()
+	zonesCache[systemID] = cached
+	zonesCacheMu.Unlock()
+
+	return cached
+}
+
+func (c *cachedZones) orNil() *cachedZones {
+	if len(c.zones) == 0 {
+		return nil
+	}
+	return c
+}
+
+// contain checks if any of zones contains the location
+func (c *cachedZones) contain(lon, lat float64) bool {
+	if !c.bbox.Contains(lon, lat) {
+		return false
+	}
+	for _, zone := range c.zones {
+		if zone.contains(lon, lat) {
+			return true
+		}
+	}
+	return false
+}
+
+// zonesBounds returns bounding box of zones polygons
+func zonesBounds(zones []geofencingZone) geo.BBox {
+	b := geo.BBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	extend := func(polygon [][][]float64) {
+		for _, ring := range polygon {
+			rb := geo.Bounds(ring)
+			b = geo.BBox{math.Min(b[0], rb[0]), math.Min(b[1], rb[1]), math.Max(b[2], rb[2]), math.Max(b[3], rb[3])}
+		}
+	}
+
+	for _, zone := range zones {
+		if zone.Geometry == nil {
+			continue
+		}
+		switch zone.Geometry.Type {
+		case gj.GeometryMultiPolygon:
+			for _, polygon := range zone.Geometry.MultiPolygon {
+				extend(polygon)
+			}
+		case gj.GeometryPolygon:
+			extend(zone.Geometry.Polygon)
+		}
+	}
+	return b
+}
+
+func getGeofencingZones(ctx context.Context, systemID, lang string) ([]geofencingZone, error) {
+	resp, err := loadGeofencingZones(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Data.GeofencingZones.Features, nil
+}
+
+// loadGeofencingZones returns geofencing zones feed with its header
+func loadGeofencingZones(ctx context.Context, systemID, lang string) (*geofencingZonesResponse, error) {
+	url, err := feedURL(ctx, systemID, "geofencing_zones", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp geofencingZonesResponse
+	done := tracing.StartFetch(ctx, systemID, "geofencing_zones", url)
+	err = loadJSON(url, &resp)
+	done(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load geofencing zones %q", url)
+	}
+
+	return &resp, nil
+}
+
+func (z geofencingZone) activeAt(t time.Time) bool {
+	if z.Properties.Start != nil && t.Before(z.Properties.Start.Time()) {
+		return false
+	}
+	if z.Properties.End != nil && !t.Before(z.Properties.End.Time()) {
+		return false
+	}
+	return true
+}
+
+func (z geofencingZone) contains(lon, lat float64) bool {
+	if z.Geometry == nil {
+		return false
+	}
+
+	switch z.Geometry.Type {
+	case gj.GeometryMultiPolygon:
+		return geo.MultiPolygonContains(z.Geometry.MultiPolygon, lon, lat)
+	case gj.GeometryPolygon:
+		return geo.PolygonContains(z.Geometry.Polygon, lon, lat)
+	}
+
+	return false
+}
+
+// ruleFor returns the first rule applicable to given vehicle type,
+// rules without vehicle types apply to all vehicles.
+// Without vehicle type the first rule for all vehicles is returned,
+// or the first rule if every rule is limited to some vehicle types.
+func (z geofencingZone) ruleFor(vehicleTypeID string) *geofencingRule {
+	for i, rule := range z.Properties.Rules {
+		if len(rule.VehicleTypeIDs) == 0 {
+			return &z.Properties.Rules[i]
+		}
+		for _, id := range rule.VehicleTypeIDs {
+			if vehicleTypeID != "" && string(id) == vehicleTypeID {
+				return &z.Properties.Rules[i]
+			}
+		}
+	}
+
+	if vehicleTypeID == "" && len(z.Properties.Rules) > 0 {
+		return &z.Properties.Rules[0]
+	}
+	return nil
+}
+
+// getPointRules evaluates zones containing the point.
+// Outside of any zone riding is not restricted.
+// When zones overlap, the rule of the zone listed first takes precedence.
+func getPointRules(zones []geofencingZone, lon, lat float64, vehicleTypeID string, at time.Time) pointRules {
+	result := pointRules{
+		Zones:              []string{},
+		RideAllowed:        true,
+		RideThroughAllowed: true,
+	}
+
+	var applied bool
+	for _, zone := range zones {
+		if !zone.activeAt(at) || !zone.contains(lon, lat) {
+			continue
+		}
+
+		result.InZone = true
+		result.Zones = append(result.Zones, zone.Properties.Name)
+
+		if applied {
+			continue
+		}
+
+		if rule := zone.ruleFor(vehicleTypeID); rule != nil {
+			result.RideAllowed = rule.RideAllowed
+			result.RideThroughAllowed = rule.RideThroughAllowed
+			result.MaximumSpeedKph = rule.MaximumSpeedKph
+			result.StationParking = rule.StationParking
+			applied = true
+		}
+	}
+
+	return result
+}
+
+func convertGeofencingZonesToGeoJSON(zones []geofencingZone) *gj.FeatureCollection {
+	fc := gj.NewFeatureCollection()
+	fc.Features = []*gj.Feature{}
+
+	for _, zone := range zones {
+		if zone.Geometry == nil {
+			continue
+		}
+
+		rules := []map[string]interface{}{}
+		for _, rule := range zone.Properties.Rules {
+			r := map[string]interface{}{
+				"rideAllowed":        rule.RideAllowed,
+				"rideThroughAllowed": rule.RideThroughAllowed,
+			}
+			if len(rule.VehicleTypeIDs) > 0 {
+				r["vehicleTypeIDs"] = rule.VehicleTypeIDs
+			}
+			if rule.MaximumSpeedKph != nil {
+				r["maximumSpeedKph"] = *rule.MaximumSpeedKph
+			}
+			if rule.StationParking != nil {
+				r["stationParking"] = *rule.StationParking
+			}
+			rules = append(rules, r)
+		}
+
+		props := map[string]interface{}{
+			"rules": rules,
+		}
+		if zone.Properties.Name != "" {
+			props["name"] = zone.Properties.Name
+		}
+		if zone.Properties.Start != nil {
+			props["start"] = zone.Properties.Start.Unix()
+		}
+		if zone.Properties.End != nil {
+			props["end"] = zone.Properties.End.Unix()
+		}
+
+		fc.Features = append(fc.Features, &gj.Feature{
+			Geometry:   zone.Geometry,
+			Properties: props,
+		})
+	}
+
+	return fc
+}
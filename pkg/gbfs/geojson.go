@@ -1,15 +1,22 @@
 package gbfs
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/liangyaopei/structmap"
 	gj "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
 
 	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/geo"
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
 )
 
 type stationProperties struct {
@@ -22,28 +29,64 @@ type stationProperties struct {
 	RegionID    gbfs.ID `map:"regionID,omitempty"`
 }
 
+// requestError is returned when request parameters are invalid
+type requestError struct {
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{message: fmt.Sprintf(format, args...)}
+}
+
 func HandlerGeoJSON() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		serviceID := r.URL.Query().Get("systemID")
-		url, err := RedisClient.GetFeedURL(serviceID, "station_information", "en")
+		query := r.URL.Query()
+		lang := requestLanguage(r)
+
+		var fc *gj.FeatureCollection
+		var cache *feedCache
+		var err error
+
+		switch layer := query.Get("layer"); layer {
+		case "", "stations":
+			fc, cache, err = stationsGeoJSON(r.Context(), query, lang)
+		case "geofencing":
+			fc, cache, err = geofencingGeoJSON(r.Context(), query, lang)
+		case "systems":
+			fc, err = systemsGeoJSON(r.Context())
+		default:
+			err = badRequest("Unknown layer %q", layer)
+		}
+
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get system %q feed URL: %v", serviceID, err), 500)
+			code := 500
+			if _, ok := err.(*requestError); ok {
+				code = 400
+			}
+			http.Error(w, err.Error(), code)
 			return
 		}
 
-		si, err := Client.LoadStationInformation(url)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get station information %q: %v", url, err), 500)
+		w.Header().Add("Vary", "Accept-Language")
+		if cache != nil && writeFeedCacheHeaders(w, r, *cache, lang) {
 			return
 		}
 
-		fc := convertStationsToGeoJSON(si.Data.Stations)
-		b, err := json.MarshalIndent(fc, "", "  ")
+		b, err := json.Marshal(fc)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to marshal station information: %v", err), 500)
+			http.Error(w, fmt.Sprintf("Failed to marshal GeoJSON: %v", err), 500)
+			return
+		}
+
+		if cache == nil && writeContentCacheHeaders(w, r, b, systemsMaxAge) {
 			return
 		}
 
+		w.Header().Set("Content-Type", "application/geo+json")
 		_, err = w.Write(b)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to write response: %v", err), 500)
@@ -51,38 +94,147 @@ func HandlerGeoJSON() http.Handler {
 	})
 }
 
+func stationsGeoJSON(ctx context.Context, query url.Values, lang string) (*gj.FeatureCollection, *feedCache, error) {
+	serviceID := query.Get("systemID")
+
+	zoom := -1
+	if v := query.Get("zoom"); v != "" {
+		var err error
+		zoom, err = strconv.Atoi(v)
+		if err != nil || zoom < 0 {
+			return nil, nil, badRequest("Invalid zoom %q", v)
+		}
+	}
+
+	var bbox *geo.BBox
+	if v := query.Get("bbox"); v != "" {
+		b, err := geo.ParseBBox(v)
+		if err != nil {
+			return nil, nil, badRequest("Invalid bbox %q: %v", v, err)
+		}
+		bbox = &b
+	}
+
+	url, err := feedURL(ctx, serviceID, "station_information", lang)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get system %q feed URL: %v", serviceID, err)
+	}
+
+	done := tracing.StartFetch(ctx, serviceID, "station_information", url)
+	si, err := Client.LoadStationInformation(url)
+	done(err)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get station information %q: %v", url, err)
+	}
+
+	cache := &feedCache{}
+	cache.add(si.Header)
+
+	stations := si.Data.Stations
+	if regionID := query.Get("regionID"); regionID != "" {
+		stations = filterStationsByRegion(stations, regionID)
+	}
+	if bbox != nil {
+		stations = filterStationsByBBox(stations, *bbox)
+	}
+
+	if zoom >= 0 && zoom <= maxClusterZoom {
+		var status []gbfs.StationStatus
+		resp, err := loadStationStatus(ctx, serviceID, lang)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to get station status for clustering", "system", serviceID, "error", err)
+		} else {
+			status = resp.Data.Stations
+			cache.add(resp.Header)
+		}
+		return convertClustersToGeoJSON(clusterStations(stations, status, zoom)), cache, nil
+	}
+
+	return convertStationsToGeoJSON(stations), cache, nil
+}
+
+func geofencingGeoJSON(ctx context.Context, query url.Values, lang string) (*gj.FeatureCollection, *feedCache, error) {
+	serviceID := query.Get("systemID")
+
+	resp, err := loadGeofencingZones(ctx, serviceID, lang)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get geofencing zones: %v", err)
+	}
+
+	cache := &feedCache{}
+	cache.add(resp.Header)
+
+	return convertGeofencingZonesToGeoJSON(resp.Data.GeofencingZones.Features), cache, nil
+}
+
+func systemsGeoJSON(ctx context.Context) (*gj.FeatureCollection, error) {
+	systems, err := RedisClient.GetSystems()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get systems: %v", err)
+	}
+
+	coverages, err := RedisClient.GetCoverages()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get systems coverage: %v", err)
+	}
+
+	return convertSystemsToGeoJSON(systems, coverages), nil
+}
+
+func filterStationsByBBox(stations []gbfs.StationInformation, bbox geo.BBox) []gbfs.StationInformation {
+	result := []gbfs.StationInformation{}
+	for _, station := range stations {
+		if bbox.Contains(station.Lon, station.Lat) {
+			result = append(result, station)
+		}
+	}
+	return result
+}
+
 func convertStationsToGeoJSON(stations []gbfs.StationInformation) *gj.FeatureCollection {
 	fc := gj.NewFeatureCollection()
 	fc.Features = []*gj.Feature{}
 
 	for _, station := range stations {
-		props := stationProperties{
-			ID:          station.ID,
-			Name:        station.Name,
-			Address:     station.Address,
-			CrossStreet: station.CrossStreet,
-			Capacity:    station.Capacity,
-			ShortName:   station.ShortName,
-			RegionID:    station.RegionID,
-		}
-		m, err := structmap.StructToMap(&props, "map", "")
+		feature, err := convertStationToGeoJSON(station)
 		if err != nil {
-			log.Printf("Failed to convert struct to map: %v", err)
+			slog.Warn("Failed to convert station", "error", err)
 			continue
 		}
-
-		feature := gj.Feature{
-			Geometry: &gj.Geometry{
-				Type: gj.GeometryPoint,
-				Point: []float64{
-					station.Lon,
-					station.Lat,
-				},
-			},
-			Properties: m,
-		}
-		fc.Features = append(fc.Features, &feature)
+		fc.Features = append(fc.Features, feature)
 	}
 
 	return fc
 }
+
+func convertStationToGeoJSON(station gbfs.StationInformation) (*gj.Feature, error) {
+	props := stationProperties{
+		ID:          station.ID,
+		Name:        station.Name,
+		Address:     station.Address,
+		CrossStreet: station.CrossStreet,
+		Capacity:    station.Capacity,
+		ShortName:   station.ShortName,
+		RegionID:    station.RegionID,
+	}
+	m, err := structmap.StructToMap(&props, "map", "")
+	if err != nil {
+		return nil, errors.Wrap(err, "convert struct to map")
+	}
+
+	// structmap does not convert maps, so capacity is added separately
+	if len(station.VehicleTypeCapacity) > 0 {
+		m["vehicleTypeCapacity"] = map[string]int(station.VehicleTypeCapacity)
+	}
+
+	return &gj.Feature{
+		Geometry: &gj.Geometry{
+			Type: gj.GeometryPoint,
+			Point: []float64{
+				station.Lon,
+				station.Lat,
+			},
+		},
+		Properties: m,
+	}, nil
+}
@@ -1,17 +1,27 @@
 package gbfs
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/chuhlomin/gbfs-go"
+	"github.com/gorilla/websocket"
 	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
 	"github.com/graphql-go/handler"
 	"github.com/graphql-go/relay"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 
+	"github.com/chuhlomin/gbfs-tools/pkg/geo"
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
 	"github.com/chuhlomin/gbfs-tools/pkg/redis"
 	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
 )
 
 var Client *gbfs.Client
@@ -19,15 +29,137 @@ var RedisClient *redis.Client
 
 var Schema graphql.Schema
 
-func HandlerGraphQL() http.Handler {
-	return handler.New(&handler.Config{
+// GraphQLConfig configures GraphQL endpoint
+type GraphQLConfig struct {
+	// GraphiQL and Playground enable in-browser IDEs
+	GraphiQL   bool
+	Playground bool
+	// Introspection enables __schema and __type queries
+	Introspection bool
+	// PersistedQueries are queries by their IDs (usually sha256 hashes)
+	PersistedQueries map[string]string
+	// PersistedQueriesOnly rejects queries not listed in PersistedQueries
+	PersistedQueriesOnly bool
+	// CacheMaxAge is max-age of successful responses to GET queries, 0 disables caching
+	CacheMaxAge time.Duration
+}
+
+func HandlerGraphQL(config GraphQLConfig) http.Handler {
+	ide := handler.New(&handler.Config{
 		Schema:     &Schema,
 		Pretty:     true,
-		GraphiQL:   true,
-		Playground: true,
+		GraphiQL:   config.GraphiQL,
+		Playground: config.Playground,
+	})
+
+	persisted := newPersistedQueries(config.PersistedQueries, config.PersistedQueriesOnly)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			handleSubscriptions(w, r, persisted)
+			return
+		}
+
+		if (config.GraphiQL || config.Playground) && isIDERequest(r) {
+			// IDE page is rendered without query parameters,
+			// so queries are always executed below with all checks
+			page := r.Clone(r.Context())
+			page.URL.RawQuery = ""
+			ide.ServeHTTP(w, page)
+			return
+		}
+
+		req, err := parseGraphQLRequest(r)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to parse request: %v", err), 400)
+			return
+		}
+
+		query, qerr := persisted.resolve(r.Context(), req)
+		if qerr != nil {
+			writeGraphQLErrors(w, *qerr)
+			return
+		}
+
+		doc, op := parseOperation(query, req.OperationName)
+		if op != nil {
+			if r.Method == http.MethodGet && op.Operation != ast.OperationTypeQuery {
+				writeGraphQLErrors(w, graphQLError("METHOD_NOT_ALLOWED", "Only queries can be sent with GET"))
+				return
+			}
+
+			if !config.Introspection && hasIntrospection(doc, op) {
+				writeGraphQLErrors(w, graphQLError("INTROSPECTION_DISABLED", "Introspection is disabled"))
+				return
+			}
+
+			if err := checkQueryComplexity(Schema, doc, op, req.Variables); err != nil {
+				writeGraphQLErrors(w, err.(*complexityError).formatted())
+				return
+			}
+		}
+
+		name, operationType := operationInfo(op)
+		ctx, span := tracing.Start(
+			r.Context(),
+			"graphql "+operationType,
+			attribute.String("graphql.operation.name", name),
+			attribute.String("graphql.operation.type", operationType),
+		)
+
+		start := time.Now()
+		result := graphql.Do(graphql.Params{
+			Schema:         Schema,
+			RequestString:  query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        withLanguage(withLoaders(ctx), r.Header.Get("Accept-Language")),
+		})
+		duration := time.Since(start)
+
+		metrics.ObserveGraphQL(name, operationType, duration, result.HasErrors())
+		if result.HasErrors() {
+			err := errors.New(result.Errors[0].Message)
+			tracing.End(span, err)
+			slog.WarnContext(ctx, "GraphQL operation failed", "operation", name, "type", operationType, "duration", duration, "errors", len(result.Errors), "error", err)
+		} else {
+			tracing.End(span, nil)
+			slog.DebugContext(ctx, "GraphQL operation", "operation", name, "type", operationType, "duration", duration)
+		}
+
+		if r.Method == http.MethodGet && config.CacheMaxAge > 0 && op != nil && !result.HasErrors() {
+			w.Header().Set("Cache-Control", cacheControl(w, config.CacheMaxAge))
+			w.Header().Add("Vary", "Accept-Language")
+		}
+
+		writeGraphQLResult(w, result)
 	})
 }
 
+// operationInfo returns name and type of operation, op is nil for invalid queries
+func operationInfo(op *ast.OperationDefinition) (name, operationType string) {
+	if op == nil {
+		return "", "invalid"
+	}
+	if op.Name != nil {
+		name = op.Name.Value
+	}
+	return name, op.Operation
+}
+
+// isIDERequest reports whether browser requests GraphiQL or Playground page
+func isIDERequest(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	if _, raw := r.URL.Query()["raw"]; raw {
+		return false
+	}
+
+	accept := r.Header.Get("Accept")
+	return !strings.Contains(accept, "application/json") && strings.Contains(accept, "text/html")
+}
+
 func init() {
 	// systemInformationType := graphql.NewObject(graphql.ObjectConfig{
 	// 	Name:        "SystemInformation",
@@ -115,206 +247,1637 @@ func init() {
 		},
 	})
 
-	systemType := graphql.NewObject(graphql.ObjectConfig{
-		Name:        "System",
-		Description: "Bikeshare system",
+	coordinatesType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Coordinates",
+		Description: "WGS 84 coordinates",
 		Fields: graphql.Fields{
-			"id": &graphql.Field{
-				Type:        graphql.String,
-				Description: "System ID",
+			"lat": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Latitude",
 			},
-			"countryCode": &graphql.Field{
+			"lon": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Longitude",
+			},
+		},
+	})
+
+	regionCountField := func(description string, count func(*regionCounts) interface{}) *graphql.Field {
+		return &graphql.Field{
+			Type:        graphql.Int,
+			Description: description,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				r, ok := p.Source.(region)
+				if !ok {
+					return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+				}
+
+				counts, err := r.aggregates.get(r.ID)
+				if err != nil {
+					return nil, err
+				}
+				return count(counts), nil
+			},
+		}
+	}
+
+	regionType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Region",
+		Description: "System region",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
 				Type:        graphql.String,
-				Description: "Country Code",
+				Description: "Identifier of the region",
 			},
 			"name": &graphql.Field{
 				Type:        graphql.String,
-				Description: "Name",
+				Description: "Public name of the region",
 			},
-			"location": &graphql.Field{
-				Type:        graphql.String,
-				Description: "Location",
+			"stationsCount": regionCountField(
+				"Number of stations in the region",
+				func(c *regionCounts) interface{} { return c.StationsCount },
+			),
+			"capacity": regionCountField(
+				"Total number of docking points installed at stations in the region",
+				func(c *regionCounts) interface{} { return c.Capacity },
+			),
+			"numBikesAvailable": regionCountField(
+				"Number of vehicles available for rental at stations in the region",
+				func(c *regionCounts) interface{} { return int(c.NumBikesAvailable) },
+			),
+			"numDocksAvailable": regionCountField(
+				"Number of docks accepting vehicle returns at stations in the region",
+				func(c *regionCounts) interface{} { return int(c.NumDocksAvailable) },
+			),
+		},
+	})
+
+	nodeDefinitions := relay.NewNodeDefinitions(relay.NodeDefinitionsConfig{
+		IDFetcher: func(id string, info graphql.ResolveInfo, ctx context.Context) (interface{}, error) {
+			return fetchNode(ctx, id, contextLanguage(ctx))
+		},
+	})
+
+	formFactorEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "FormFactor",
+		Description: "Vehicle form factor",
+		Values: graphql.EnumValueConfigMap{
+			"BICYCLE": &graphql.EnumValueConfig{Value: gbfs.FormFactorBicycle},
+			"CAR":     &graphql.EnumValueConfig{Value: gbfs.FormFactorCar},
+			"MOPED":   &graphql.EnumValueConfig{Value: gbfs.FormFactorMoped},
+			"SCOOTER": &graphql.EnumValueConfig{Value: gbfs.FormFactorScooter},
+			"OTHER":   &graphql.EnumValueConfig{Value: gbfs.FormFactorOther},
+		},
+	})
+
+	propulsionEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "Propulsion",
+		Description: "Primary propulsion type of the vehicle",
+		Values: graphql.EnumValueConfigMap{
+			"HUMAN": &graphql.EnumValueConfig{
+				Value:       gbfs.PropulsionType(gbfs.PropulsionTypeHuman),
+				Description: "Pedal or foot propulsion",
 			},
-			"url": &graphql.Field{
-				Type:        graphql.String,
-				Description: "URL",
+			"ELECTRIC_ASSIST": &graphql.EnumValueConfig{
+				Value:       gbfs.PropulsionType(gbfs.PropulsionTypeElectricAssist),
+				Description: "Provides power only alongside human propulsion",
 			},
-			"autoDiscoveryUrl": &graphql.Field{
-				Type:        graphql.String,
-				Description: "Auto-discovery URL",
+			"ELECTRIC": &graphql.EnumValueConfig{
+				Value:       gbfs.PropulsionType(gbfs.PropulsionTypeElectric),
+				Description: "Contains throttle mode with a battery-powered motor",
 			},
-			"languages": &graphql.Field{
-				Type:        &graphql.List{OfType: graphql.String},
-				Description: "Available GTFS languages",
-				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					source := p.Source
-					switch t := source.(type) {
-					case structs.System:
-						system := source.(structs.System)
-						return RedisClient.GetFeedsLanguages(system.ID)
-
-					default:
-						return nil, fmt.Errorf("Unexpected type %T in source: %v", t, p.Source)
-					}
-				},
+			"COMBUSTION": &graphql.EnumValueConfig{
+				Value:       gbfs.PropulsionType(gbfs.PropulsionTypeCombustion),
+				Description: "Contains throttle mode with a gas engine-powered motor",
 			},
-			"feeds": &graphql.Field{
-				Type:        &graphql.List{OfType: feedType},
-				Description: "SystemFeeds",
-				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					source := p.Source
-					switch t := source.(type) {
+		},
+	})
 
-					case *structs.System:
-						system := source.(*structs.System)
-						return RedisClient.GetFeeds(system.ID)
-					default:
-						return nil, fmt.Errorf("Unexpected type %T in source: %v", t, p.Source)
+	vehicleTypeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "VehicleType",
+		Description: "Type of vehicles available for rent",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of a vehicle type",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					vehicleType, ok := p.Source.(gbfs.VehicleType)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
 					}
+					return vehicleType.VehicleTypeID, nil
 				},
 			},
+			"formFactor": &graphql.Field{
+				Type:        formFactorEnum,
+				Description: "Vehicle form factor",
+			},
+			"propulsionType": &graphql.Field{
+				Type:        propulsionEnum,
+				Description: "Primary propulsion type of the vehicle",
+			},
+			"maxRangeMeters": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Furthest distance in meters that the vehicle can travel without recharging or refueling",
+			},
+			"name": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Public name of this vehicle type",
+			},
 		},
 	})
 
-	stationStatusType := graphql.NewObject(graphql.ObjectConfig{
-		Name:        "StationStatus",
-		Description: "Station status",
+	vehicleAvailabilityType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "VehicleAvailability",
+		Description: "Number of vehicles or docks of a specific vehicle type",
 		Fields: graphql.Fields{
-			"id": &graphql.Field{
+			"vehicleTypeID": &graphql.Field{
 				Type:        graphql.String,
-				Description: "Identifier of a station",
+				Description: "Identifier of a vehicle type",
 			},
-			"numBikesAvailable": &graphql.Field{
+			"count": &graphql.Field{
 				Type:        graphql.Int,
-				Description: "Number of functional vehicles physically at the station that may be offered for rental",
+				Description: "Number of vehicles or docks",
 			},
-			"numBikesDisabled": &graphql.Field{
-				Type:        graphql.Int,
-				Description: "Number of disabled vehicles of any type at the station",
+		},
+	})
+
+	vehicleType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Vehicle",
+		Description: "Vehicle available for rent outside of stations or docked at a station",
+		Interfaces:  []*graphql.Interface{nodeDefinitions.NodeInterface},
+		IsTypeOf: func(p graphql.IsTypeOfParams) bool {
+			_, ok := p.Value.(vehicle)
+			return ok
+		},
+		Fields: graphql.Fields{
+			"id": relay.GlobalIDField(nodeTypeVehicle, func(obj interface{}, info graphql.ResolveInfo, ctx context.Context) (string, error) {
+				v, ok := obj.(vehicle)
+				if !ok {
+					return "", fmt.Errorf("Unexpected type %T in source: %v", obj, obj)
+				}
+				return v.SystemID + ":" + string(v.BikeID), nil
+			}),
+			"vehicleID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of a vehicle in the system",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					v, ok := p.Source.(vehicle)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return v.BikeID, nil
+				},
 			},
-			"numDocksAvailable": &graphql.Field{
-				Type:        graphql.Int,
-				Description: "Number of functional docks physically at the station that are able to accept vehicles for return",
+			"lat": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Latitude of the vehicle",
 			},
-			"isInstalled": &graphql.Field{
-				Type:        graphql.Boolean,
-				Description: "Is the station currently on the street?",
+			"lon": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Longitude of the vehicle",
 			},
-			"isRenting": &graphql.Field{
+			"isReserved": &graphql.Field{
 				Type:        graphql.Boolean,
-				Description: "Is the station currently renting vehicles?",
+				Description: "Is the vehicle currently reserved?",
 			},
-			"isReturning": &graphql.Field{
+			"isDisabled": &graphql.Field{
 				Type:        graphql.Boolean,
-				Description: "Is the station accepting vehicle returns?",
+				Description: "Is the vehicle currently disabled?",
+			},
+			"currentRangeMeters": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Furthest distance in meters that the vehicle can travel without recharging or refueling",
+			},
+			"stationID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of a station where the vehicle is located",
+			},
+			"pricingPlanID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of a pricing plan applied to the vehicle",
 			},
 			"lastReported": &graphql.Field{
 				Type:        graphql.DateTime,
-				Description: "The last time this station reported its status to the operator's backend",
+				Description: "The last time this vehicle reported its status to the operator's backend",
+			},
+			"vehicleTypeID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of a vehicle type",
+			},
+			"vehicleType": &graphql.Field{
+				Type:        vehicleTypeType,
+				Description: "Type of the vehicle",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					v, ok := p.Source.(vehicle)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					if v.VehicleType == nil {
+						return nil, nil
+					}
+					return *v.VehicleType, nil
+				},
 			},
 		},
 	})
 
-	systemsConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
-		Name:     "System",
-		NodeType: systemType,
+	stationType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Station",
+		Description: "Station information",
+		Interfaces:  []*graphql.Interface{nodeDefinitions.NodeInterface},
+		IsTypeOf: func(p graphql.IsTypeOfParams) bool {
+			_, ok := p.Value.(station)
+			return ok
+		},
+		Fields: graphql.Fields{
+			"id": relay.GlobalIDField(nodeTypeStation, func(obj interface{}, info graphql.ResolveInfo, ctx context.Context) (string, error) {
+				s, ok := obj.(station)
+				if !ok {
+					return "", fmt.Errorf("Unexpected type %T in source: %v", obj, obj)
+				}
+				return s.SystemID + ":" + string(s.ID), nil
+			}),
+			"stationID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of a station in the system",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					s, ok := p.Source.(station)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return s.ID, nil
+				},
+			},
+			"name": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Public name of the station",
+			},
+			"shortName": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Short name or other type of identifier",
+			},
+			"lat": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Latitude of the station",
+			},
+			"lon": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Longitude of the station",
+			},
+			"address": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Address where station is located",
+			},
+			"crossStreet": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Cross street or landmark where the station is located",
+			},
+			"regionID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of the region where station is located",
+			},
+			"region": &graphql.Field{
+				Type:        regionType,
+				Description: "Region where station is located",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					s, ok := p.Source.(station)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return findRegion(p.Context, s.SystemID, fieldLanguage(p), s.RegionID)
+				},
+			},
+			"postCode": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Postal code where station is located",
+			},
+			"capacity": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Number of total docking points installed at this station",
+			},
+			"vehicleTypeCapacity": &graphql.Field{
+				Type:        &graphql.List{OfType: vehicleAvailabilityType},
+				Description: "Number of total docking points installed at this station for each vehicle type",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					s, ok := p.Source.(station)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return capacityByVehicleType(s.VehicleTypeCapacity), nil
+				},
+			},
+		},
 	})
 
-	systemsArgs := relay.ConnectionArgs
-	systemsArgs["countryCode"] = &graphql.ArgumentConfig{
-		Type: graphql.String,
-	}
-
-	stationStatusConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
-		Name:     "StationStatus",
-		NodeType: stationStatusType,
+	alertTypeEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "AlertType",
+		Description: "Type of the alert",
+		Values: graphql.EnumValueConfigMap{
+			"SYSTEM_CLOSURE": &graphql.EnumValueConfig{
+				Value: gbfs.AlertSystemClosure,
+			},
+			"STATION_CLOSURE": &graphql.EnumValueConfig{
+				Value: gbfs.AlertStationClosure,
+			},
+			"STATION_MOVE": &graphql.EnumValueConfig{
+				Value: gbfs.AlertStationMove,
+			},
+			"OTHER": &graphql.EnumValueConfig{
+				Value: gbfs.AlertOther,
+			},
+		},
 	})
 
-	stationStatusArgs := relay.ConnectionArgs
-	stationStatusArgs["systemID"] = &graphql.ArgumentConfig{
-		Type:        graphql.String,
-		Description: "System ID",
-	}
+	alertTimeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "AlertTime",
+		Description: "Time window when the alert is in effect",
+		Fields: graphql.Fields{
+			"start": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "Start time of the alert",
+			},
+			"end": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "End time of the alert, empty if the alert is in effect until further notice",
+			},
+		},
+	})
 
-	queryType := graphql.NewObject(graphql.ObjectConfig{
-		Name: "Query",
+	alertType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Alert",
+		Description: "Ad-hoc alert about the system",
 		Fields: graphql.Fields{
-			"systems": &graphql.Field{
-				Type: systemsConnectionDefinition.ConnectionType,
-				Args: systemsArgs,
+			"id": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of the alert",
+			},
+			"type": &graphql.Field{
+				Type:        alertTypeEnum,
+				Description: "Type of the alert",
+			},
+			"times": &graphql.Field{
+				Type:        &graphql.List{OfType: alertTimeType},
+				Description: "Time windows when the alert is in effect, empty if the alert is always in effect",
+			},
+			"stations": &graphql.Field{
+				Type:        &graphql.List{OfType: stationType},
+				Description: "Stations affected by the alert",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					args := relay.NewConnectionArguments(p.Args)
-
-					countryCode, filterByCountryCode := p.Args["countryCode"]
+					a, ok := p.Source.(alert)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					if len(a.StationIDs) == 0 {
+						return []station{}, nil
+					}
 
-					systems, err := RedisClient.GetSystems()
+					stations, err := requestStations(p.Context, a.SystemID, fieldLanguage(p))
 					if err != nil {
 						return nil, err
 					}
-					var result []interface{}
-					for i := range systems {
-						if filterByCountryCode && systems[i].CountryCode != countryCode {
-							// log.Printf("Filter out %s (%s)", systems[i].ID, systems[i].CountryCode)
-							continue
-						}
-
-						result = append(result, systems[i])
-					}
-
-					return relay.ConnectionFromArray(result, args), nil
-				},
-			},
-			"system": &graphql.Field{
-				Type: systemType,
-				Args: graphql.FieldConfigArgument{
-					"id": &graphql.ArgumentConfig{
-						Type:        graphql.String,
-						Description: "System ID",
-					},
-				},
-				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					return RedisClient.GetSystem(fmt.Sprintf("%v", p.Args["id"]))
+					return filterStationsByIDs(stations, a.StationIDs), nil
 				},
 			},
-			"stationStatus": &graphql.Field{
-				Type: stationStatusConnectionDefinition.ConnectionType,
-				Args: stationStatusArgs,
+			"regions": &graphql.Field{
+				Type:        &graphql.List{OfType: regionType},
+				Description: "Regions affected by the alert",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					args := relay.NewConnectionArguments(p.Args)
-
-					if _, ok := p.Args["systemID"]; !ok {
-						return nil, fmt.Errorf("Missing systemID argument")
+					a, ok := p.Source.(alert)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					if len(a.RegionIDs) == 0 {
+						return []region{}, nil
 					}
-					systemID := fmt.Sprintf("%v", p.Args["systemID"])
 
-					stations, err := getStationStatus(systemID)
+					regions, err := requestRegions(p.Context, a.SystemID, fieldLanguage(p))
 					if err != nil {
 						return nil, err
 					}
-
-					var result []interface{}
-					for i := range stations {
-						result = append(result, stations[i])
-					}
-
-					return relay.ConnectionFromArray(result, args), nil
+					return filterRegionsByIDs(regions, a.RegionIDs), nil
 				},
 			},
-			// "system_information": &graphql.Field{
-			// 	Type: systemInformationType,
-			// 	Args: graphql.FieldConfigArgument{
-			// 		"id": &graphql.ArgumentConfig{
-			// 			Type:        graphql.String,
-			// 			Description: "System ID",
-			// 		},
-			// 		"lang": &graphql.ArgumentConfig{
-			// 			Type:        graphql.String,
-			// 			Description: "Language",
-			// 		},
-			// 	},
-			// 	Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-			// 		system, err := RedisClient.GetSystem(p.Args["id"])
-			// 		systems, err := GetSystemInformation()
+			"url": &graphql.Field{
+				Type:        graphql.String,
+				Description: "URL where the customer can learn more information about the alert",
+			},
+			"summary": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Short summary of the alert",
+			},
+			"description": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Detailed description of the alert",
+			},
+			"lastUpdated": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "Last time the alert was updated",
+			},
+		},
+	})
+
+	decimalType := graphql.NewScalar(graphql.ScalarConfig{
+		Name:        "Decimal",
+		Description: "Exact decimal number serialized as string with its decimal places, e.g. \"2.50\"",
+		Serialize: func(value interface{}) interface{} {
+			switch v := value.(type) {
+			case Decimal:
+				return v.String()
+			case *Decimal:
+				if v == nil {
+					return nil
+				}
+				return v.String()
+			}
+			return nil
+		},
+		ParseValue: func(value interface{}) interface{} {
+			s, ok := value.(string)
+			if !ok {
+				return nil
+			}
+			d, err := ParseDecimal(s)
+			if err != nil {
+				return nil
+			}
+			return d
+		},
+		ParseLiteral: func(valueAST ast.Value) interface{} {
+			var s string
+			switch v := valueAST.(type) {
+			case *ast.StringValue:
+				s = v.Value
+			case *ast.IntValue:
+				s = v.Value
+			case *ast.FloatValue:
+				s = v.Value
+			default:
+				return nil
+			}
+			d, err := ParseDecimal(s)
+			if err != nil {
+				return nil
+			}
+			return d
+		},
+	})
+
+	pricingSegmentType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PricingSegment",
+		Description: "Rate charged every interval of minutes or kilometers within the segment",
+		Fields: graphql.Fields{
+			"start": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Number of minutes or kilometers when the segment starts charging",
+			},
+			"rate": &graphql.Field{
+				Type:        decimalType,
+				Description: "Rate charged each interval, in plan currency",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					segment, ok := p.Source.(PricingSegment)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return segment.Rate, nil
+				},
+			},
+			"interval": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Interval in minutes or kilometers at which the rate is charged",
+			},
+			"end": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Number of minutes or kilometers when the segment stops charging, empty if it never stops",
+			},
+		},
+	})
+
+	pricingPlanType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PricingPlan",
+		Description: "System pricing plan",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of the pricing plan",
+			},
+			"url": &graphql.Field{
+				Type:        graphql.String,
+				Description: "URL where the customer can learn more about the plan",
+			},
+			"name": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Name of the plan",
+			},
+			"currency": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Currency used to pay the fare, ISO 4217 code",
+			},
+			"price": &graphql.Field{
+				Type:        decimalType,
+				Description: "Fare price, in the unit specified by currency",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					plan, ok := p.Source.(PricingPlan)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return plan.Price, nil
+				},
+			},
+			"isTaxable": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Will additional tax be added to the base price?",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					plan, ok := p.Source.(PricingPlan)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return bool(plan.IsTaxable), nil
+				},
+			},
+			"description": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Customer-readable description of the plan",
+			},
+			"perKmPricing": &graphql.Field{
+				Type:        &graphql.List{OfType: pricingSegmentType},
+				Description: "Distance-based pricing segments, in kilometers",
+			},
+			"perMinPricing": &graphql.Field{
+				Type:        &graphql.List{OfType: pricingSegmentType},
+				Description: "Time-based pricing segments, in minutes",
+			},
+		},
+	})
+
+	segmentCostType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "SegmentCost",
+		Description: "Amount charged by a single pricing segment",
+		Fields: graphql.Fields{
+			"unit": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Segment unit: min or km",
+			},
+			"segment": &graphql.Field{
+				Type:        pricingSegmentType,
+				Description: "Pricing segment",
+			},
+			"charges": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Number of times the rate was charged",
+			},
+			"subtotal": &graphql.Field{
+				Type:        decimalType,
+				Description: "Amount charged by the segment",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					sc, ok := p.Source.(SegmentCost)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return sc.Subtotal, nil
+				},
+			},
+		},
+	})
+
+	tripCostType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "TripCost",
+		Description: "Breakdown of estimated trip cost",
+		Fields: graphql.Fields{
+			"planID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of the pricing plan",
+			},
+			"currency": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Currency, ISO 4217 code",
+			},
+			"basePrice": &graphql.Field{
+				Type:        decimalType,
+				Description: "Plan price charged for every trip",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					cost, ok := p.Source.(TripCost)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return cost.BasePrice, nil
+				},
+			},
+			"segments": &graphql.Field{
+				Type:        &graphql.List{OfType: segmentCostType},
+				Description: "Per-minute and per-kilometer charges",
+			},
+			"total": &graphql.Field{
+				Type:        decimalType,
+				Description: "Total trip cost, excluding taxes",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					cost, ok := p.Source.(TripCost)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return cost.Total, nil
+				},
+			},
+		},
+	})
+
+	userTypeEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "UserType",
+		Description: "Type of the user",
+		Values: graphql.EnumValueConfigMap{
+			"MEMBER": &graphql.EnumValueConfig{
+				Value: gbfs.UserTypeMember,
+			},
+			"NONMEMBER": &graphql.EnumValueConfig{
+				Value: gbfs.UserTypeNonMember,
+			},
+		},
+	})
+
+	weekdayEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "Weekday",
+		Description: "Day of the week",
+		Values: graphql.EnumValueConfigMap{
+			"MON": &graphql.EnumValueConfig{Value: gbfs.Weekday(time.Monday)},
+			"TUE": &graphql.EnumValueConfig{Value: gbfs.Weekday(time.Tuesday)},
+			"WED": &graphql.EnumValueConfig{Value: gbfs.Weekday(time.Wednesday)},
+			"THU": &graphql.EnumValueConfig{Value: gbfs.Weekday(time.Thursday)},
+			"FRI": &graphql.EnumValueConfig{Value: gbfs.Weekday(time.Friday)},
+			"SAT": &graphql.EnumValueConfig{Value: gbfs.Weekday(time.Saturday)},
+			"SUN": &graphql.EnumValueConfig{Value: gbfs.Weekday(time.Sunday)},
+		},
+	})
+
+	rentalHoursType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "RentalHours",
+		Description: "Hours of operation for the system",
+		Fields: graphql.Fields{
+			"userTypes": &graphql.Field{
+				Type:        &graphql.List{OfType: userTypeEnum},
+				Description: "Types of users the hours apply to",
+			},
+			"days": &graphql.Field{
+				Type:        &graphql.List{OfType: weekdayEnum},
+				Description: "Days of the week the hours apply to",
+			},
+			"startTime": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Start time of the hours of operation, HH:MM:SS in system timezone",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					h, ok := p.Source.(rentalHours)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return h.StartTime.String(), nil
+				},
+			},
+			"endTime": &graphql.Field{
+				Type:        graphql.String,
+				Description: "End time of the hours of operation, HH:MM:SS in system timezone, may exceed 24:00:00",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					h, ok := p.Source.(rentalHours)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return h.EndTime.String(), nil
+				},
+			},
+		},
+	})
+
+	calendarType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Calendar",
+		Description: "Operating season of the system",
+		Fields: graphql.Fields{
+			"startMonth": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Starting month for the system operations (1-12)",
+			},
+			"startDay": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Starting day for the system operations (1-31)",
+			},
+			"startYear": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Starting year for the system operations, empty if the season repeats every year",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					c, ok := p.Source.(gbfs.Calendar)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					if c.StartYear == 0 {
+						return nil, nil
+					}
+					return int(c.StartYear), nil
+				},
+			},
+			"endMonth": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Ending month for the system operations (1-12)",
+			},
+			"endDay": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Ending day for the system operations (1-31)",
+			},
+			"endYear": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Ending year for the system operations, empty if the season repeats every year",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					c, ok := p.Source.(gbfs.Calendar)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					if c.EndYear == 0 {
+						return nil, nil
+					}
+					return int(c.EndYear), nil
+				},
+			},
+		},
+	})
+
+	systemHealthEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "SystemHealth",
+		Description: "Health of the system, as checked by writer",
+		Values: graphql.EnumValueConfigMap{
+			"OK": &graphql.EnumValueConfig{
+				Value:       structs.SystemHealthOK,
+				Description: "All checked feeds were loaded",
+			},
+			"DEGRADED": &graphql.EnumValueConfig{
+				Value:       structs.SystemHealthDegraded,
+				Description: "Auto-discovery feed was loaded, but some feeds were not",
+			},
+			"DOWN": &graphql.EnumValueConfig{
+				Value:       structs.SystemHealthDown,
+				Description: "Auto-discovery feed could not be loaded",
+			},
+			"UNKNOWN": &graphql.EnumValueConfig{
+				Value:       systemHealthUnknown,
+				Description: "System was not checked yet",
+			},
+		},
+	})
+
+	systemStatusType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "SystemStatus",
+		Description: "Result of the last system check",
+		Fields: graphql.Fields{
+			"version": &graphql.Field{
+				Type:        graphql.String,
+				Description: "GBFS version of auto-discovery feed",
+			},
+			"health": &graphql.Field{
+				Type:        systemHealthEnum,
+				Description: "System health",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					status, ok := p.Source.(*structs.SystemStatus)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return systemHealth(status), nil
+				},
+			},
+			"error": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Error of the last check, if system is not healthy",
+			},
+			"stationsCount": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Number of stations",
+			},
+			"checkedAt": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "Time of the last check",
+			},
+		},
+	})
+
+	systemType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "System",
+		Description: "Bikeshare system",
+		Interfaces:  []*graphql.Interface{nodeDefinitions.NodeInterface},
+		IsTypeOf: func(p graphql.IsTypeOfParams) bool {
+			_, ok := p.Value.(*structs.System)
+			return ok
+		},
+		Fields: graphql.Fields{
+			"id": relay.GlobalIDField(nodeTypeSystem, func(obj interface{}, info graphql.ResolveInfo, ctx context.Context) (string, error) {
+				system, ok := obj.(*structs.System)
+				if !ok {
+					return "", fmt.Errorf("Unexpected type %T in source: %v", obj, obj)
+				}
+				return system.ID, nil
+			}),
+			"systemID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "System ID",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return system.ID, nil
+				},
+			},
+			"countryCode": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Country Code",
+			},
+			"name": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Name",
+			},
+			"location": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Location",
+			},
+			"url": &graphql.Field{
+				Type:        graphql.String,
+				Description: "URL",
+			},
+			"autoDiscoveryUrl": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Auto-discovery URL",
+			},
+			"isEnabled": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the system listed in systems.csv?",
+			},
+			"status": &graphql.Field{
+				Type:        systemStatusType,
+				Description: "Result of the last system check, null if system was not checked yet",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+
+					statuses, err := RedisClient.GetSystemStatuses()
+					if err != nil {
+						return nil, err
+					}
+					if status, ok := statuses[system.ID]; ok {
+						return status, nil
+					}
+					return nil, nil
+				},
+			},
+			"languages": &graphql.Field{
+				Type:        &graphql.List{OfType: graphql.String},
+				Description: "Available GTFS languages",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					source := p.Source
+					switch t := source.(type) {
+					case *structs.System:
+						system := source.(*structs.System)
+						return loadLanguages(p.Context, system.ID), nil
+
+					default:
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", t, p.Source)
+					}
+				},
+			},
+			"bbox": &graphql.Field{
+				Type:        &graphql.List{OfType: graphql.Float},
+				Description: "Bounding box of the service area: min longitude, min latitude, max longitude, max latitude",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					coverage, err := getSystemCoverage(p.Source)
+					if err != nil || coverage == nil {
+						return nil, err
+					}
+					return coverage.BBox[:], nil
+				},
+			},
+			"center": &graphql.Field{
+				Type:        coordinatesType,
+				Description: "Centroid of the service area",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					coverage, err := getSystemCoverage(p.Source)
+					if err != nil || coverage == nil || len(coverage.Center) < 2 {
+						return nil, err
+					}
+					return coordinates{
+						Lat: coverage.Center[1],
+						Lon: coverage.Center[0],
+					}, nil
+				},
+			},
+			"alerts": &graphql.Field{
+				Type:        &graphql.List{OfType: alertType},
+				Description: "System alerts",
+				Args: graphql.FieldConfigArgument{
+					"activeAt": &graphql.ArgumentConfig{
+						Type:        graphql.DateTime,
+						Description: "Return only alerts in effect at given time",
+					},
+					"types": &graphql.ArgumentConfig{
+						Type:        &graphql.List{OfType: alertTypeEnum},
+						Description: "Return only alerts of given types",
+					},
+					"lang": langArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+
+					var activeAt time.Time
+					if v, ok := p.Args["activeAt"].(time.Time); ok {
+						activeAt = v
+					}
+
+					var types []gbfs.AlertType
+					if v, ok := p.Args["types"].([]interface{}); ok {
+						for _, t := range v {
+							if alertType, ok := t.(gbfs.AlertType); ok {
+								types = append(types, alertType)
+							}
+						}
+					}
+
+					return getAlerts(p.Context, system.ID, fieldLanguage(p), activeAt, types)
+				},
+			},
+			"regions": &graphql.Field{
+				Type:        &graphql.List{OfType: regionType},
+				Description: "System regions",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return requestRegions(p.Context, system.ID, fieldLanguage(p))
+				},
+			},
+			"hours": &graphql.Field{
+				Type:        &graphql.List{OfType: rentalHoursType},
+				Description: "Hours of operation",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return getRentalHours(p.Context, system.ID, fieldLanguage(p))
+				},
+			},
+			"calendar": &graphql.Field{
+				Type:        &graphql.List{OfType: calendarType},
+				Description: "Operating seasons",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return getCalendars(p.Context, system.ID, fieldLanguage(p))
+				},
+			},
+			"isOpen": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the system operating at given time, according to its hours and calendar?",
+				Args: graphql.FieldConfigArgument{
+					"at": &graphql.ArgumentConfig{
+						Type:        graphql.DateTime,
+						Description: "Time to check, current time if omitted",
+					},
+					"userType": &graphql.ArgumentConfig{
+						Type:        userTypeEnum,
+						Description: "Check hours for the user type only",
+					},
+					"lang": langArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+
+					at := time.Now()
+					if v, ok := p.Args["at"].(time.Time); ok {
+						at = v
+					}
+
+					userType, _ := p.Args["userType"].(gbfs.UserType)
+
+					hours, err := getRentalHours(p.Context, system.ID, fieldLanguage(p))
+					if err != nil {
+						return nil, err
+					}
+
+					calendars, err := getCalendars(p.Context, system.ID, fieldLanguage(p))
+					if err != nil {
+						return nil, err
+					}
+
+					if len(hours) == 0 && len(calendars) == 0 {
+						return true, nil
+					}
+
+					loc := getSystemLocation(p.Context, system.ID)
+					return isSystemOpen(hours, calendars, at.In(loc), userType), nil
+				},
+			},
+			"vehicleTypes": &graphql.Field{
+				Type:        &graphql.List{OfType: vehicleTypeType},
+				Description: "Types of vehicles available for rent in the system",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return getVehicleTypes(p.Context, system.ID, fieldLanguage(p))
+				},
+			},
+			"pricingPlans": &graphql.Field{
+				Type:        &graphql.List{OfType: pricingPlanType},
+				Description: "System pricing plans",
+				Args:        graphql.FieldConfigArgument{"lang": langArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					system, ok := p.Source.(*structs.System)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return getPricingPlans(p.Context, system.ID, fieldLanguage(p))
+				},
+			},
+			"feeds": &graphql.Field{
+				Type:        &graphql.List{OfType: feedType},
+				Description: "SystemFeeds",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					source := p.Source
+					switch t := source.(type) {
+
+					case *structs.System:
+						system := source.(*structs.System)
+						return loadFeeds(p.Context, system.ID), nil
+					default:
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", t, p.Source)
+					}
+				},
+			},
+		},
+	})
+
+	stationStatusType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "StationStatus",
+		Description: "Station status",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Identifier of a station",
+			},
+			"numBikesAvailable": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Number of functional vehicles physically at the station that may be offered for rental",
+			},
+			"numBikesDisabled": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Number of disabled vehicles of any type at the station",
+			},
+			"numDocksAvailable": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Number of functional docks physically at the station that are able to accept vehicles for return",
+			},
+			"isInstalled": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the station currently on the street?",
+				Resolve:     resolveGBFS,
+			},
+			"isRenting": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the station currently renting vehicles?",
+				Resolve:     resolveGBFS,
+			},
+			"isReturning": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the station accepting vehicle returns?",
+				Resolve:     resolveGBFS,
+			},
+			"lastReported": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "The last time this station reported its status to the operator's backend",
+				Resolve:     resolveGBFS,
+			},
+			"vehicleTypesAvailable": &graphql.Field{
+				Type:        &graphql.List{OfType: vehicleAvailabilityType},
+				Description: "Number of available vehicles of each type at the station",
+			},
+			"vehicleDocksAvailable": &graphql.Field{
+				Type:        &graphql.List{OfType: vehicleAvailabilityType},
+				Description: "Number of available docks for each vehicle type at the station",
+			},
+		},
+	})
+
+	geoJSONType := graphql.NewScalar(graphql.ScalarConfig{
+		Name:        "GeoJSON",
+		Description: "GeoJSON geometry object",
+		Serialize: func(value interface{}) interface{} {
+			return value
+		},
+	})
+
+	geofencingRuleType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "GeofencingRule",
+		Description: "Geofencing zone rule",
+		Fields: graphql.Fields{
+			"vehicleTypeIDs": &graphql.Field{
+				Type:        &graphql.List{OfType: graphql.String},
+				Description: "Vehicle types the rule applies to, empty if the rule applies to all vehicles",
+			},
+			"rideAllowed": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the undocked (\"free bike\") ride allowed to start and end in the zone?",
+			},
+			"rideThroughAllowed": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the ride allowed to travel through the zone?",
+			},
+			"maximumSpeedKph": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Maximum speed allowed in the zone, in kilometers per hour",
+			},
+			"stationParking": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Must vehicles be parked at stations in the zone?",
+			},
+		},
+	})
+
+	geofencingZoneType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "GeofencingZone",
+		Description: "Geofencing zone",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Public name of the zone",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					zone, ok := p.Source.(geofencingZone)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return zone.Properties.Name, nil
+				},
+			},
+			"start": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "Start time of the zone, zone is active since then",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					zone, ok := p.Source.(geofencingZone)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					if zone.Properties.Start == nil {
+						return nil, nil
+					}
+					return zone.Properties.Start.Time(), nil
+				},
+			},
+			"end": &graphql.Field{
+				Type:        graphql.DateTime,
+				Description: "End time of the zone, zone is not active after that",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					zone, ok := p.Source.(geofencingZone)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					if zone.Properties.End == nil {
+						return nil, nil
+					}
+					return zone.Properties.End.Time(), nil
+				},
+			},
+			"rules": &graphql.Field{
+				Type:        &graphql.List{OfType: geofencingRuleType},
+				Description: "Rules applied in the zone, the first matching rule takes precedence",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					zone, ok := p.Source.(geofencingZone)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return zone.Properties.Rules, nil
+				},
+			},
+			"geometry": &graphql.Field{
+				Type:        geoJSONType,
+				Description: "Zone boundaries, GeoJSON MultiPolygon",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					zone, ok := p.Source.(geofencingZone)
+					if !ok {
+						return nil, fmt.Errorf("Unexpected type %T in source: %v", p.Source, p.Source)
+					}
+					return zone.Geometry, nil
+				},
+			},
+		},
+	})
+
+	pointRulesType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PointRules",
+		Description: "Geofencing rules applied at the location",
+		Fields: graphql.Fields{
+			"inZone": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the location inside any geofencing zone?",
+			},
+			"zones": &graphql.Field{
+				Type:        &graphql.List{OfType: graphql.String},
+				Description: "Names of the zones containing the location",
+			},
+			"rideAllowed": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the ride allowed to start and end at the location?",
+			},
+			"rideThroughAllowed": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Is the ride allowed to travel through the location?",
+			},
+			"maximumSpeedKph": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Maximum speed allowed at the location, in kilometers per hour",
+			},
+			"stationParking": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Must vehicles be parked at stations?",
+			},
+		},
+	})
+
+	systemsConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
+		Name:     "System",
+		NodeType: systemType,
+	})
+
+	systemsOrderEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "SystemsOrder",
+		Description: "Order of systems",
+		Values: graphql.EnumValueConfigMap{
+			"NAME":           &graphql.EnumValueConfig{Value: systemsOrderName},
+			"COUNTRY":        &graphql.EnumValueConfig{Value: systemsOrderCountry},
+			"STATIONS_COUNT": &graphql.EnumValueConfig{Value: systemsOrderStationsCount},
+		},
+	})
+
+	systemsArgs := connectionArgs(graphql.FieldConfigArgument{
+		"countryCode": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Return only systems in the country",
+		},
+		"countries": &graphql.ArgumentConfig{
+			Type:        &graphql.List{OfType: graphql.String},
+			Description: "Return only systems in any of the countries",
+		},
+		"feeds": &graphql.ArgumentConfig{
+			Type:        &graphql.List{OfType: graphql.String},
+			Description: "Return only systems publishing all the feeds, e.g. free_bike_status",
+		},
+		"versions": &graphql.ArgumentConfig{
+			Type:        &graphql.List{OfType: graphql.String},
+			Description: "Return only systems with any of GBFS versions, \"2\" matches any 2.x version",
+		},
+		"health": &graphql.ArgumentConfig{
+			Type:        &graphql.List{OfType: systemHealthEnum},
+			Description: "Return only systems with any of health statuses",
+		},
+		"isEnabled": &graphql.ArgumentConfig{
+			Type:        graphql.Boolean,
+			Description: "Return only enabled or disabled systems",
+		},
+		"search": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Return only systems with all the words in name or location, ignoring case and diacritics",
+		},
+		"orderBy": &graphql.ArgumentConfig{
+			Type:        systemsOrderEnum,
+			Description: "Order of systems",
+		},
+		"descending": &graphql.ArgumentConfig{
+			Type:         graphql.Boolean,
+			DefaultValue: false,
+			Description:  "Reverse order of systems",
+		},
+	})
+
+	stationStatusConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
+		Name:     "StationStatus",
+		NodeType: stationStatusType,
+	})
+
+	stationStatusArgs := connectionArgs(graphql.FieldConfigArgument{
+		"systemID": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "System ID",
+		},
+		"regionID": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Return only stations located in the region",
+		},
+		"lang": langArg,
+	})
+
+	stationsConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
+		Name:     "Station",
+		NodeType: stationType,
+	})
+
+	stationsArgs := connectionArgs(graphql.FieldConfigArgument{
+		"systemID": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "System ID",
+		},
+		"regionID": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Return only stations located in the region",
+		},
+		"lang": langArg,
+	})
+
+	vehiclesConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
+		Name:     "Vehicle",
+		NodeType: vehicleType,
+	})
+
+	vehiclesArgs := connectionArgs(graphql.FieldConfigArgument{
+		"systemID": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "System ID",
+		},
+		"formFactor": &graphql.ArgumentConfig{
+			Type:        formFactorEnum,
+			Description: "Return only vehicles of the form factor",
+		},
+		"propulsion": &graphql.ArgumentConfig{
+			Type:        propulsionEnum,
+			Description: "Return only vehicles with the propulsion type",
+		},
+		"minRangeMeters": &graphql.ArgumentConfig{
+			Type:        graphql.Float,
+			Description: "Return only vehicles that can travel at least given distance",
+		},
+		"lang": langArg,
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"systems": &graphql.Field{
+				Type: systemsConnectionDefinition.ConnectionType,
+				Args: systemsArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					args := relay.NewConnectionArguments(p.Args)
+
+					filter := systemsFilter{
+						Countries: stringsArg(p.Args["countries"]),
+						Feeds:     stringsArg(p.Args["feeds"]),
+						Versions:  stringsArg(p.Args["versions"]),
+						Health:    stringsArg(p.Args["health"]),
+					}
+					if countryCode, ok := p.Args["countryCode"].(string); ok {
+						filter.Countries = append(filter.Countries, countryCode)
+					}
+					if isEnabled, ok := p.Args["isEnabled"].(bool); ok {
+						filter.IsEnabled = &isEnabled
+					}
+					filter.Search, _ = p.Args["search"].(string)
+
+					order, _ := p.Args["orderBy"].(string)
+					descending, _ := p.Args["descending"].(bool)
+
+					systems, err := getSystems(p.Context, filter, order, descending)
+					if err != nil {
+						return nil, err
+					}
+
+					var result []interface{}
+					for i := range systems {
+						result = append(result, systems[i])
+					}
+
+					return relay.ConnectionFromArray(result, args), nil
+				},
+			},
+			"system": &graphql.Field{
+				Type: systemType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "System ID or its global ID",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return RedisClient.WithContext(p.Context).GetSystem(systemIDFromArg(fmt.Sprintf("%v", p.Args["id"])))
+				},
+			},
+			"node": nodeDefinitions.NodeField,
+			"systemsAt": &graphql.Field{
+				Type:        &graphql.List{OfType: systemType},
+				Description: "Systems serving the location, closest first",
+				Args: graphql.FieldConfigArgument{
+					"lat": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.Float),
+						Description: "Latitude",
+					},
+					"lon": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.Float),
+						Description: "Longitude",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return getSystemsAt(p.Context, p.Args["lon"].(float64), p.Args["lat"].(float64))
+				},
+			},
+			"estimateTripCost": &graphql.Field{
+				Type:        tripCostType,
+				Description: "Estimate trip cost using system pricing plan",
+				Args: graphql.FieldConfigArgument{
+					"systemID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "System ID",
+					},
+					"planID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "Pricing plan ID",
+					},
+					"durationMinutes": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.Float),
+						Description: "Trip duration in minutes",
+					},
+					"distanceKm": &graphql.ArgumentConfig{
+						Type:         graphql.Float,
+						DefaultValue: 0.0,
+						Description:  "Trip distance in kilometers",
+					},
+					"lang": langArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					plans, err := getPricingPlans(p.Context, p.Args["systemID"].(string), fieldLanguage(p))
+					if err != nil {
+						return nil, err
+					}
+
+					plan, err := FindPricingPlan(plans, p.Args["planID"].(string))
+					if err != nil {
+						return nil, err
+					}
+
+					distanceKm, _ := p.Args["distanceKm"].(float64)
+					return EstimateTripCost(*plan, p.Args["durationMinutes"].(float64), distanceKm), nil
+				},
+			},
+			"stationStatus": &graphql.Field{
+				Type: stationStatusConnectionDefinition.ConnectionType,
+				Args: stationStatusArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					args := relay.NewConnectionArguments(p.Args)
+
+					if _, ok := p.Args["systemID"]; !ok {
+						return nil, fmt.Errorf("Missing systemID argument")
+					}
+					systemID := fmt.Sprintf("%v", p.Args["systemID"])
+
+					stations, err := getStationStatus(p.Context, systemID, fieldLanguage(p))
+					if err != nil {
+						return nil, err
+					}
+
+					if regionID, ok := p.Args["regionID"].(string); ok {
+						stations, err = filterStationStatusByRegion(p.Context, systemID, fieldLanguage(p), stations, regionID)
+						if err != nil {
+							return nil, err
+						}
+					}
+
+					var result []interface{}
+					for i := range stations {
+						result = append(result, stations[i])
+					}
+
+					return relay.ConnectionFromArray(result, args), nil
+				},
+			},
+			"stations": &graphql.Field{
+				Type: stationsConnectionDefinition.ConnectionType,
+				Args: stationsArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					args := relay.NewConnectionArguments(p.Args)
+
+					stations, err := requestStations(p.Context, p.Args["systemID"].(string), fieldLanguage(p))
+					if err != nil {
+						return nil, err
+					}
+
+					regionID, filterByRegion := p.Args["regionID"].(string)
+
+					var result []interface{}
+					for i := range stations {
+						if filterByRegion && string(stations[i].RegionID) != regionID {
+							continue
+						}
+						result = append(result, stations[i])
+					}
+
+					return relay.ConnectionFromArray(result, args), nil
+				},
+			},
+			"vehicles": &graphql.Field{
+				Type: vehiclesConnectionDefinition.ConnectionType,
+				Args: vehiclesArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					args := relay.NewConnectionArguments(p.Args)
+
+					filter := vehiclesFilter{}
+					filter.FormFactor, _ = p.Args["formFactor"].(gbfs.FormFactor)
+					filter.PropulsionType, _ = p.Args["propulsion"].(gbfs.PropulsionType)
+					filter.MinRangeMeters, _ = p.Args["minRangeMeters"].(float64)
+
+					vehicles, err := getVehicles(p.Context, p.Args["systemID"].(string), fieldLanguage(p), filter)
+					if err != nil {
+						return nil, err
+					}
+
+					var result []interface{}
+					for i := range vehicles {
+						result = append(result, vehicles[i])
+					}
+
+					return relay.ConnectionFromArray(result, args), nil
+				},
+			},
+			"geofencingZones": &graphql.Field{
+				Type: &graphql.List{OfType: geofencingZoneType},
+				Args: graphql.FieldConfigArgument{
+					"systemID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "System ID",
+					},
+					"lang": langArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return getGeofencingZones(p.Context, p.Args["systemID"].(string), fieldLanguage(p))
+				},
+			},
+			"pointRules": &graphql.Field{
+				Type:        pointRulesType,
+				Description: "Geofencing rules applied at the location right now",
+				Args: graphql.FieldConfigArgument{
+					"systemID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "System ID",
+					},
+					"lat": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.Float),
+						Description: "Latitude",
+					},
+					"lon": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.Float),
+						Description: "Longitude",
+					},
+					"vehicleTypeID": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Vehicle type ID, rules for all vehicle types are used if omitted",
+					},
+					"lang": langArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					zones, err := getGeofencingZones(p.Context, p.Args["systemID"].(string), fieldLanguage(p))
+					if err != nil {
+						return nil, err
+					}
+
+					vehicleTypeID, _ := p.Args["vehicleTypeID"].(string)
+
+					return getPointRules(
+						zones,
+						p.Args["lon"].(float64),
+						p.Args["lat"].(float64),
+						vehicleTypeID,
+						time.Now(),
+					), nil
+				},
+			},
+			// "system_information": &graphql.Field{
+			// 	Type: systemInformationType,
+			// 	Args: graphql.FieldConfigArgument{
+			// 		"id": &graphql.ArgumentConfig{
+			// 			Type:        graphql.String,
+			// 			Description: "System ID",
+			// 		},
+			// 		"lang": &graphql.ArgumentConfig{
+			// 			Type:        graphql.String,
+			// 			Description: "Language",
+			// 		},
+			// 	},
+			// 	Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			// 		system, err := RedisClient.GetSystem(p.Args["id"])
+			// 		systems, err := GetSystemInformation()
 			// 		if err != nil {
 			// 			return nil, err
 			// 		}
@@ -330,25 +1893,261 @@ func init() {
 		},
 	})
 
+	webhookType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Webhook",
+		Description: "Subscriber of system alerts changes",
+		Fields: graphql.Fields{
+			"systemID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "System ID",
+			},
+			"url": &graphql.Field{
+				Type:        graphql.String,
+				Description: "URL receiving alert.created, alert.updated and alert.resolved events",
+			},
+			"secret": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Secret used to sign events, see X-GBFS-Signature header; returned only by addWebhook",
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"addWebhook": &graphql.Field{
+				Type:        webhookType,
+				Description: "Subscribe to system alerts changes, requires API key",
+				Args: graphql.FieldConfigArgument{
+					"systemID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "System ID",
+					},
+					"url": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "URL to POST events to",
+					},
+					"secret": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Secret used to sign events, generated if omitted",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					secret, _ := p.Args["secret"].(string)
+					return addWebhook(p.Context, p.Args["systemID"].(string), p.Args["url"].(string), secret)
+				},
+			},
+			"removeWebhook": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Unsubscribe from system alerts changes, returns false if subscription was not found; requires API key used in addWebhook",
+				Args: graphql.FieldConfigArgument{
+					"systemID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "System ID",
+					},
+					"url": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "URL used in addWebhook",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return removeWebhook(p.Context, p.Args["systemID"].(string), p.Args["url"].(string))
+				},
+			},
+		},
+	})
+
+	vehiclesChangeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "VehiclesChange",
+		Description: "Changes of system vehicles since previous update",
+		Fields: graphql.Fields{
+			"updated": &graphql.Field{
+				Type:        &graphql.List{OfType: vehicleType},
+				Description: "New vehicles and vehicles which status has changed",
+			},
+			"removed": &graphql.Field{
+				Type:        &graphql.List{OfType: graphql.String},
+				Description: "Global IDs of vehicles which are no longer available or left the bounding box",
+			},
+		},
+	})
+
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"stationStatusChanged": &graphql.Field{
+				Type:        &graphql.List{OfType: stationStatusType},
+				Description: "Statuses of stations that changed since previous update",
+				Args: graphql.FieldConfigArgument{
+					"systemID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "System ID",
+					},
+					"stationIDs": &graphql.ArgumentConfig{
+						Type:        &graphql.List{OfType: graphql.String},
+						Description: "Watch only given stations",
+					},
+					"lang": langArg,
+				},
+				Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+					var stationIDs []string
+					if ids, ok := p.Args["stationIDs"].([]interface{}); ok {
+						for _, id := range ids {
+							stationIDs = append(stationIDs, fmt.Sprintf("%v", id))
+						}
+					}
+
+					return subscribeStationStatus(p.Context, p.Args["systemID"].(string), fieldLanguage(p), stationIDs)
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+			"vehiclesChanged": &graphql.Field{
+				Type:        vehiclesChangeType,
+				Description: "Vehicles that changed since previous update",
+				Args: graphql.FieldConfigArgument{
+					"systemID": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.String),
+						Description: "System ID",
+					},
+					"bbox": &graphql.ArgumentConfig{
+						Type:        &graphql.List{OfType: graphql.Float},
+						Description: "Watch only vehicles inside the box [minLon, minLat, maxLon, maxLat]",
+					},
+					"lang": langArg,
+				},
+				Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+					var bbox *geo.BBox
+					if values, ok := p.Args["bbox"].([]interface{}); ok {
+						b, err := parseBBoxArg(values)
+						if err != nil {
+							return nil, err
+						}
+						bbox = &b
+					}
+
+					return subscribeVehicles(p.Context, p.Args["systemID"].(string), fieldLanguage(p), bbox)
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
 	var err error
 	Schema, err = graphql.NewSchema(graphql.SchemaConfig{
-		Query: queryType,
+		Query:        queryType,
+		Mutation:     mutationType,
+		Subscription: subscriptionType,
 	})
 	if err != nil {
 		panic(err)
 	}
+
+	traceResolvers(Schema)
+}
+
+// connectionArgs returns relay connection arguments extended with extra ones,
+// relay.ConnectionArgs is copied as it's shared between connections
+func connectionArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	result := graphql.FieldConfigArgument{}
+	for name, arg := range relay.ConnectionArgs {
+		result[name] = arg
+	}
+	for name, arg := range extra {
+		result[name] = arg
+	}
+	return result
+}
+
+type coordinates struct {
+	Lat float64
+	Lon float64
+}
+
+// parseBBoxArg converts GraphQL list argument into bounding box
+func parseBBoxArg(values []interface{}) (geo.BBox, error) {
+	var b geo.BBox
+
+	if len(values) != 4 {
+		return b, fmt.Errorf("bbox must contain 4 values, got %d", len(values))
+	}
+
+	for i, v := range values {
+		f, ok := v.(float64)
+		if !ok {
+			return b, fmt.Errorf("bbox value %v is not a number", v)
+		}
+		b[i] = f
+	}
+
+	if b[1] > b[3] {
+		return b, fmt.Errorf("min latitude %v is greater than max latitude %v", b[1], b[3])
+	}
+
+	return b, nil
+}
+
+// stringsArg returns list argument as strings
+func stringsArg(arg interface{}) []string {
+	values, _ := arg.([]interface{})
+
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		result = append(result, fmt.Sprintf("%v", v))
+	}
+	return result
+}
+
+func getSystemCoverage(source interface{}) (*structs.Coverage, error) {
+	system, ok := source.(*structs.System)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected type %T in source: %v", source, source)
+	}
+
+	return RedisClient.GetCoverage(system.ID)
+}
+
+func getStationInformation(ctx context.Context, systemID, lang string) ([]gbfs.StationInformation, error) {
+	url, err := feedURL(ctx, systemID, "station_information", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "station_information", url)
+	si, err := Client.LoadStationInformation(url)
+	done(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load station information %q", url)
+	}
+
+	return si.Data.Stations, nil
+}
+
+func getStationStatus(ctx context.Context, systemID, lang string) ([]gbfs.StationStatus, error) {
+	status, err := loadStationStatus(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	return status.Data.Stations, nil
 }
 
-func getStationStatus(systemID string) ([]gbfs.StationStatus, error) {
-	url, err := RedisClient.GetFeedURL(systemID, "station_status", "en")
+// loadStationStatus returns station status feed with its header
+func loadStationStatus(ctx context.Context, systemID, lang string) (*gbfs.StationStatusResponse, error) {
+	url, err := feedURL(ctx, systemID, "station_status", lang)
 	if err != nil {
-		return nil, errors.Wrapf(err, "get station status for %q", systemID)
+		return nil, err
 	}
 
+	done := tracing.StartFetch(ctx, systemID, "station_status", url)
 	status, err := Client.LoadStationStatus(url)
+	done(err)
 	if err != nil {
 		return nil, errors.Wrapf(err, "load station statis %q", url)
 	}
 
-	return status.Data.Stations, nil
+	return status, nil
 }
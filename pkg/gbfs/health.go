@@ -0,0 +1,133 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/redis"
+)
+
+// shutdown is closed when server starts shutting down
+var (
+	shutdown     = make(chan struct{})
+	shutdownOnce sync.Once
+	draining     atomic.Bool
+)
+
+// Drain makes server not ready, so load balancer stops sending it new requests
+func Drain() {
+	draining.Store(true)
+}
+
+// Shutdown drains server and disconnects stream and subscription clients,
+// long-lived connections would block server shutdown otherwise
+func Shutdown() {
+	Drain()
+	shutdownOnce.Do(func() { close(shutdown) })
+}
+
+type readinessCheck struct {
+	OK      bool       `json:"ok"`
+	Error   string     `json:"error,omitempty"`
+	Count   *int       `json:"count,omitempty"`
+	LastRun *time.Time `json:"lastRun,omitempty"`
+	Age     string     `json:"age,omitempty"`
+}
+
+type readiness struct {
+	Status       string                    `json:"status"`
+	ShuttingDown bool                      `json:"shuttingDown,omitempty"`
+	Checks       map[string]readinessCheck `json:"checks"`
+}
+
+// HandlerHealth reports that process is up
+func HandlerHealth() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})
+}
+
+// HandlerReady reports whether server can serve requests:
+// Redis is reachable, systems are loaded in memory (loading is retried if they are not)
+// and writer finished not earlier than writerMaxAge ago (0 disables the check)
+func HandlerReady(writerMaxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redisClient := RedisClient.WithContext(r.Context())
+
+		systemsCheck := checkSystems(redisClient)
+
+		redisCheck := readinessCheck{OK: true}
+		writerCheck := readinessCheck{Error: "Redis is not reachable"}
+		if err := redisClient.Ping(); err != nil {
+			redisCheck = readinessCheck{Error: err.Error()}
+		} else {
+			writerCheck = checkWriter(redisClient.GetLastRun, writerMaxAge)
+		}
+
+		resp := readiness{
+			Status:       "ok",
+			ShuttingDown: draining.Load(),
+			Checks: map[string]readinessCheck{
+				"redis":   redisCheck,
+				"systems": systemsCheck,
+				"writer":  writerCheck,
+			},
+		}
+
+		status := http.StatusOK
+		if resp.ShuttingDown || !redisCheck.OK || !systemsCheck.OK || !writerCheck.OK {
+			resp.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// checkSystems checks that systems are loaded in memory,
+// systems and feeds are loaded again if loading on start failed or Redis was empty
+func checkSystems(redisClient *redis.Client) readinessCheck {
+	count := redisClient.CachedSystems()
+	if count == 0 {
+		if err := redisClient.CacheAllSystems(); err != nil {
+			return readinessCheck{Error: err.Error(), Count: &count}
+		}
+		if err := redisClient.CacheAllFeeds(); err != nil {
+			return readinessCheck{Error: err.Error(), Count: &count}
+		}
+		count = redisClient.CachedSystems()
+	}
+
+	if count == 0 {
+		return readinessCheck{Error: "no systems loaded", Count: &count}
+	}
+	return readinessCheck{OK: true, Count: &count}
+}
+
+// checkWriter checks that writer finished not earlier than maxAge ago
+func checkWriter(lastRun func() (time.Time, error), maxAge time.Duration) readinessCheck {
+	t, err := lastRun()
+	if err != nil {
+		return readinessCheck{Error: err.Error()}
+	}
+	if t.IsZero() {
+		if maxAge == 0 {
+			return readinessCheck{OK: true}
+		}
+		return readinessCheck{Error: "writer has not run yet"}
+	}
+
+	age := time.Since(t).Round(time.Second)
+	check := readinessCheck{OK: true, LastRun: &t, Age: age.String()}
+	if maxAge > 0 && age > maxAge {
+		check.OK = false
+		check.Error = "writer last run is older than " + maxAge.String()
+	}
+	return check
+}
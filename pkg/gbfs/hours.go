@@ -0,0 +1,242 @@
+package gbfs
+
+// https://github.com/NABSA/gbfs/blob/master/gbfs.md#system_hoursjson
+// https://github.com/NABSA/gbfs/blob/master/gbfs.md#system_calendarjson
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+const day = 24 * 60 * 60 // seconds
+
+// rentalHours is loaded without gbfs-go,
+// because it fails to parse end_time past midnight, like "25:00:00"
+type rentalHours struct {
+	UserTypes []gbfs.UserType `json:"user_types"`
+	Days      []gbfs.Weekday  `json:"days"`
+	StartTime clockTime       `json:"start_time"`
+	EndTime   clockTime       `json:"end_time"`
+}
+
+type systemHoursResponse struct {
+	gbfs.Header
+	Data struct {
+		RentalHours []rentalHours `json:"rental_hours"`
+	} `json:"data"`
+}
+
+// clockTime is a number of seconds since midnight,
+// may exceed 24 hours for ranges ending on the next day
+type clockTime int
+
+func (c *clockTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("parse %q as time, expected HH:MM:SS", s)
+	}
+
+	var seconds int
+	for i, multiplier := range []int{3600, 60, 1} {
+		if i >= len(parts) {
+			break
+		}
+		v, err := strconv.Atoi(parts[i])
+		if err != nil || v < 0 {
+			return fmt.Errorf("parse %q as time, expected HH:MM:SS", s)
+		}
+		seconds += v * multiplier
+	}
+
+	*c = clockTime(seconds)
+	return nil
+}
+
+func (c clockTime) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", c/3600, c%3600/60, c%60)
+}
+
+func getRentalHours(ctx context.Context, systemID, lang string) ([]rentalHours, error) {
+	if !hasFeed(ctx, systemID, "system_hours") {
+		return []rentalHours{}, nil
+	}
+
+	url, err := feedURL(ctx, systemID, "system_hours", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp systemHoursResponse
+	done := tracing.StartFetch(ctx, systemID, "system_hours", url)
+	err = loadJSON(url, &resp)
+	done(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load system hours %q", url)
+	}
+
+	return resp.Data.RentalHours, nil
+}
+
+func getCalendars(ctx context.Context, systemID, lang string) ([]gbfs.Calendar, error) {
+	if !hasFeed(ctx, systemID, "system_calendar") {
+		return []gbfs.Calendar{}, nil
+	}
+
+	url, err := feedURL(ctx, systemID, "system_calendar", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "system_calendar", url)
+	resp, err := Client.LoadSystemCalendar(url)
+	done(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load system calendar %q", url)
+	}
+
+	return resp.Data.Calendars, nil
+}
+
+// getSystemLocation returns system timezone from system_information,
+// falls back to UTC
+func getSystemLocation(ctx context.Context, systemID string) *time.Location {
+	url, err := feedURL(ctx, systemID, "system_information", "")
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get system information", "system", systemID, "error", err)
+		return time.UTC
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "system_information", url)
+	si, err := Client.LoadSystemInformation(url)
+	done(err)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load system information", "system", systemID, "url", url, "error", err)
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(si.Data.Timezone)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load timezone", "system", systemID, "timezone", si.Data.Timezone, "error", err)
+		return time.UTC
+	}
+
+	return loc
+}
+
+// isSystemOpen checks whether rentals are possible at given time.
+// Time must be in the system timezone. Empty userType matches any user type.
+func isSystemOpen(hours []rentalHours, calendars []gbfs.Calendar, t time.Time, userType gbfs.UserType) bool {
+	if len(calendars) > 0 {
+		var inSeason bool
+		for _, c := range calendars {
+			if calendarContains(c, t) {
+				inSeason = true
+				break
+			}
+		}
+		if !inSeason {
+			return false
+		}
+	}
+
+	if len(hours) == 0 {
+		return true
+	}
+
+	seconds := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	today := t.Weekday()
+	yesterday := t.AddDate(0, 0, -1).Weekday()
+
+	for _, h := range hours {
+		if !hoursApplyTo(h, userType) {
+			continue
+		}
+
+		start, end := int(h.StartTime), int(h.EndTime)
+		if end <= start { // overnight range, like 22:00 – 02:00
+			end += day
+		}
+
+		if hoursInclude(h, today) && seconds >= start && seconds < end {
+			return true
+		}
+
+		// range started yesterday and continues after midnight
+		if hoursInclude(h, yesterday) && seconds+day >= start && seconds+day < end {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hoursApplyTo(h rentalHours, userType gbfs.UserType) bool {
+	if userType == "" || len(h.UserTypes) == 0 {
+		return true
+	}
+
+	for _, t := range h.UserTypes {
+		if t == userType {
+			return true
+		}
+	}
+	return false
+}
+
+func hoursInclude(h rentalHours, weekday time.Weekday) bool {
+	for _, d := range h.Days {
+		if time.Weekday(d) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarContains checks if date is within calendar dates,
+// calendars without years repeat every year and may span New Year
+func calendarContains(c gbfs.Calendar, t time.Time) bool {
+	date := t.Year()*10000 + int(t.Month())*100 + t.Day()
+	monthDay := int(t.Month())*100 + t.Day()
+
+	if c.StartYear != 0 {
+		start := int(c.StartYear)*10000 + int(c.StartMonth)*100 + int(c.StartDay)
+		if date < start {
+			return false
+		}
+	}
+
+	if c.EndYear != 0 {
+		end := int(c.EndYear)*10000 + int(c.EndMonth)*100 + int(c.EndDay)
+		if date > end {
+			return false
+		}
+	}
+
+	if c.StartYear != 0 && c.EndYear != 0 {
+		return true
+	}
+
+	start := int(c.StartMonth)*100 + int(c.StartDay)
+	end := int(c.EndMonth)*100 + int(c.EndDay)
+
+	if start <= end {
+		return monthDay >= start && monthDay <= end
+	}
+	return monthDay >= start || monthDay <= end
+}
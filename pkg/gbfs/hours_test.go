@@ -0,0 +1,198 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chuhlomin/gbfs-go"
+)
+
+func TestClockTimeUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  clockTime
+	}{
+		{`"08:00:00"`, 8 * 3600},
+		{`"08:30"`, 8*3600 + 30*60},
+		{`"24:00:00"`, day},
+		{`"25:30:15"`, 25*3600 + 30*60 + 15},
+	}
+
+	for _, tt := range tests {
+		var c clockTime
+		if err := json.Unmarshal([]byte(tt.input), &c); err != nil {
+			t.Errorf("unmarshal %s error: %v", tt.input, err)
+			continue
+		}
+		if c != tt.want {
+			t.Errorf("unmarshal %s = %s, want %s", tt.input, c, tt.want)
+		}
+	}
+
+	for _, input := range []string{`"8"`, `"08:xx:00"`, `"-1:00:00"`, `"1:2:3:4"`, `800`} {
+		var c clockTime
+		if err := json.Unmarshal([]byte(input), &c); err == nil {
+			t.Errorf("unmarshal %s error = nil, want error", input)
+		}
+	}
+}
+
+func TestIsSystemOpen(t *testing.T) {
+	clock := func(h, m int) clockTime { return clockTime(h*3600 + m*60) }
+	days := func(weekdays ...time.Weekday) []gbfs.Weekday {
+		result := make([]gbfs.Weekday, len(weekdays))
+		for i, d := range weekdays {
+			result[i] = gbfs.Weekday(d)
+		}
+		return result
+	}
+	// 2024-01-01 is Monday
+	at := func(month time.Month, d, h, m int) time.Time {
+		return time.Date(2024, month, d, h, m, 0, 0, time.UTC)
+	}
+
+	weekdays := []rentalHours{{
+		Days:      days(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday),
+		StartTime: clock(6, 0),
+		EndTime:   clock(22, 0),
+	}}
+	overnight := []rentalHours{{
+		Days:      days(time.Friday, time.Saturday),
+		StartTime: clock(22, 0),
+		EndTime:   clock(2, 0),
+	}}
+	pastMidnight := []rentalHours{{
+		Days:      days(time.Saturday),
+		StartTime: clock(20, 0),
+		EndTime:   clock(26, 0),
+	}}
+	members := []rentalHours{
+		{
+			UserTypes: []gbfs.UserType{gbfs.UserTypeMember},
+			Days:      days(time.Monday),
+			StartTime: clock(0, 0),
+			EndTime:   clock(24, 0),
+		},
+		{
+			UserTypes: []gbfs.UserType{gbfs.UserTypeNonMember},
+			Days:      days(time.Monday),
+			StartTime: clock(8, 0),
+			EndTime:   clock(20, 0),
+		},
+	}
+	summer := []gbfs.Calendar{{StartMonth: 4, StartDay: 1, EndMonth: 10, EndDay: 31}}
+	winter := []gbfs.Calendar{{StartMonth: 12, StartDay: 1, EndMonth: 2, EndDay: 28}}
+
+	tests := []struct {
+		name      string
+		hours     []rentalHours
+		calendars []gbfs.Calendar
+		t         time.Time
+		userType  gbfs.UserType
+		want      bool
+	}{
+		{"no hours", nil, nil, at(1, 1, 3, 0), "", true},
+		{"within range", weekdays, nil, at(1, 1, 12, 0), "", true},
+		{"at start", weekdays, nil, at(1, 1, 6, 0), "", true},
+		{"before start", weekdays, nil, at(1, 1, 5, 59), "", false},
+		{"end is exclusive", weekdays, nil, at(1, 1, 22, 0), "", false},
+		{"day not listed", weekdays, nil, at(1, 6, 12, 0), "", false},
+
+		{"overnight before midnight", overnight, nil, at(1, 5, 23, 0), "", true},
+		{"overnight after midnight", overnight, nil, at(1, 6, 1, 30), "", true},
+		{"overnight carries into day not listed", overnight, nil, at(1, 7, 1, 30), "", true},
+		{"overnight end is exclusive", overnight, nil, at(1, 6, 2, 0), "", false},
+		{"overnight not started on previous day", overnight, nil, at(1, 5, 1, 0), "", false},
+		{"overnight before start", overnight, nil, at(1, 5, 21, 59), "", false},
+
+		{"end past 24:00 before midnight", pastMidnight, nil, at(1, 6, 21, 0), "", true},
+		{"end past 24:00 after midnight", pastMidnight, nil, at(1, 7, 1, 59), "", true},
+		{"end past 24:00 ended", pastMidnight, nil, at(1, 7, 2, 0), "", false},
+		{"end past 24:00 on day not listed", pastMidnight, nil, at(1, 6, 1, 0), "", false},
+
+		{"member hours", members, nil, at(1, 1, 21, 0), gbfs.UserTypeMember, true},
+		{"non-member hours", members, nil, at(1, 1, 21, 0), gbfs.UserTypeNonMember, false},
+		{"non-member within hours", members, nil, at(1, 1, 12, 0), gbfs.UserTypeNonMember, true},
+		{"any user type", members, nil, at(1, 1, 21, 0), "", true},
+
+		{"in season", weekdays, summer, at(6, 3, 12, 0), "", true},
+		{"out of season", weekdays, summer, at(1, 1, 12, 0), "", false},
+		{"calendar spanning New Year", nil, winter, at(1, 1, 12, 0), "", true},
+		{"out of calendar spanning New Year", nil, winter, at(3, 1, 12, 0), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSystemOpen(tt.hours, tt.calendars, tt.t, tt.userType); got != tt.want {
+				t.Errorf("isSystemOpen(%s) = %v, want %v", tt.t.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSystemOpenTimezone(t *testing.T) {
+	hours := []rentalHours{{
+		Days:      []gbfs.Weekday{gbfs.Weekday(time.Monday)},
+		StartTime: 6 * 3600,
+		EndTime:   22 * 3600,
+	}}
+
+	// Tuesday 02:00 UTC is Monday 21:00 in the system timezone
+	at := time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC)
+	loc := time.FixedZone("UTC-5", -5*3600)
+
+	if isSystemOpen(hours, nil, at, "") {
+		t.Error("isSystemOpen in UTC = true, want false")
+	}
+	if !isSystemOpen(hours, nil, at.In(loc), "") {
+		t.Error("isSystemOpen in system timezone = false, want true")
+	}
+}
+
+func TestCalendarContains(t *testing.T) {
+	date := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	summer := gbfs.Calendar{StartMonth: 4, StartDay: 1, EndMonth: 10, EndDay: 31}
+	winter := gbfs.Calendar{StartMonth: 12, StartDay: 1, EndMonth: 2, EndDay: 28}
+	winter2024 := gbfs.Calendar{StartYear: 2023, StartMonth: 12, StartDay: 1, EndYear: 2024, EndMonth: 2, EndDay: 29}
+	since2024 := gbfs.Calendar{StartYear: 2024, StartMonth: 12, StartDay: 1, EndMonth: 2, EndDay: 28}
+
+	tests := []struct {
+		name     string
+		calendar gbfs.Calendar
+		t        time.Time
+		want     bool
+	}{
+		{"within season", summer, date(2024, 6, 15), true},
+		{"first day", summer, date(2024, 4, 1), true},
+		{"last day", summer, date(2024, 10, 31), true},
+		{"before season", summer, date(2024, 3, 31), false},
+		{"after season", summer, date(2024, 11, 1), false},
+
+		{"New Year's Eve", winter, date(2024, 12, 31), true},
+		{"New Year's Day", winter, date(2025, 1, 1), true},
+		{"before winter", winter, date(2024, 11, 30), false},
+		{"after winter", winter, date(2024, 3, 1), false},
+
+		{"within years", winter2024, date(2024, 1, 15), true},
+		{"leap day", winter2024, date(2024, 2, 29), true},
+		{"before start year", winter2024, date(2023, 11, 30), false},
+		{"after end year", winter2024, date(2024, 12, 15), false},
+
+		{"start year only", since2024, date(2024, 12, 15), true},
+		{"start year only next year", since2024, date(2025, 1, 10), true},
+		{"before start year only", since2024, date(2023, 12, 15), false},
+		{"start year only off season", since2024, date(2025, 6, 1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendarContains(tt.calendar, tt.t); got != tt.want {
+				t.Errorf("calendarContains(%s) = %v, want %v", tt.t.Format("2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package gbfs
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/graphql-go/graphql"
+	"golang.org/x/text/language"
+)
+
+// defaultLanguage is used when requested language is not available,
+// before falling back to the first available language alphabetically
+const defaultLanguage = "en"
+
+type languageKey struct{}
+
+// langArg is an argument of fields loading system feeds
+var langArg = &graphql.ArgumentConfig{
+	Type:        graphql.String,
+	Description: "Preferred language of system feeds, e.g. \"fr\" or Accept-Language value; request Accept-Language is used if omitted",
+}
+
+// withLanguage returns context with language requested by client,
+// used by fields without lang argument
+func withLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// fieldLanguage returns lang argument of the field or language requested by client
+func fieldLanguage(p graphql.ResolveParams) string {
+	if lang, ok := p.Args["lang"].(string); ok && lang != "" {
+		return lang
+	}
+	return contextLanguage(p.Context)
+}
+
+// contextLanguage returns language requested by client, empty if not set
+func contextLanguage(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	lang, _ := ctx.Value(languageKey{}).(string)
+	return lang
+}
+
+// requestLanguage returns language requested with lang query parameter or Accept-Language header
+func requestLanguage(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return lang
+	}
+	return r.Header.Get("Accept-Language")
+}
+
+// negotiateLanguage returns available language best matching requested one.
+// Requested language may be a single tag or Accept-Language value with several tags.
+// If none of them is available, defaultLanguage or the first available language
+// in alphabetical order is returned; empty string is returned if nothing is available.
+func negotiateLanguage(available []string, requested string) string {
+	if len(available) == 0 {
+		return ""
+	}
+
+	sorted := make([]string, len(available))
+	copy(sorted, available)
+	sort.Slice(sorted, func(i, j int) bool {
+		if (sorted[i] == defaultLanguage) != (sorted[j] == defaultLanguage) {
+			return sorted[i] == defaultLanguage
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	preferred, _, err := language.ParseAcceptLanguage(requested)
+	if err != nil || len(preferred) == 0 {
+		return sorted[0]
+	}
+
+	tags := make([]language.Tag, len(sorted))
+	for i, lang := range sorted {
+		tags[i] = language.Make(lang)
+	}
+
+	// matcher returns the first tag (fallback) when nothing matches
+	_, index, confidence := language.NewMatcher(tags).Match(preferred...)
+	if confidence == language.No {
+		return sorted[0]
+	}
+	return sorted[index]
+}
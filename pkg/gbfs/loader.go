@@ -0,0 +1,211 @@
+package gbfs
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
+	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+)
+
+type loadersKey struct{}
+
+// batchFunc loads values for all keys at once
+type batchFunc func(keys []string) (map[string]interface{}, error)
+
+// loader collects keys requested by resolvers and loads all of them with a single batch
+// when value of any key is needed. GraphQL executor calls thunks returned by load
+// only after resolving all fields on the same level, so sibling objects share one batch.
+type loader struct {
+	mu      sync.Mutex
+	batch   batchFunc
+	pending []string
+	results map[string]*loaderResult
+}
+
+type loaderResult struct {
+	done  bool
+	value interface{}
+	err   error
+}
+
+func newLoader(batch batchFunc) *loader {
+	return &loader{
+		batch:   batch,
+		results: map[string]*loaderResult{},
+	}
+}
+
+// load schedules key for loading and returns thunk resolving its value
+func (l *loader) load(key string) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.results[key] = &loaderResult{}
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		r := l.results[key]
+		if !r.done {
+			l.dispatch()
+		}
+		return r.value, r.err
+	}
+}
+
+// dispatch loads all pending keys, must be called with mu locked
+func (l *loader) dispatch() {
+	keys := l.pending
+	l.pending = nil
+
+	values, err := l.batch(keys)
+	for _, key := range keys {
+		r := l.results[key]
+		r.done = true
+		r.value = values[key]
+		r.err = err
+	}
+}
+
+// memoEntry is a value loaded once per request
+type memoEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// loaders are created for every GraphQL request,
+// so values loaded by resolvers are shared only within the request
+type loaders struct {
+	feeds *loader
+
+	mu   sync.Mutex
+	memo map[string]*memoEntry
+}
+
+func newLoaders(ctx context.Context) *loaders {
+	return &loaders{
+		feeds: newLoader(func(systemIDs []string) (map[string]interface{}, error) {
+			feeds, err := RedisClient.WithContext(ctx).GetFeedsBatch(systemIDs)
+			if err != nil {
+				return nil, err
+			}
+
+			result := make(map[string]interface{}, len(feeds))
+			for systemID, f := range feeds {
+				result[systemID] = f
+			}
+			return result, nil
+		}),
+		memo: map[string]*memoEntry{},
+	}
+}
+
+// withLoaders returns context with new request-scoped loaders
+func withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, newLoaders(ctx))
+}
+
+// loadersFrom returns request-scoped loaders,
+// new loaders are returned if context has none (e.g. for subscriptions,
+// where every event must be resolved with fresh data)
+func loadersFrom(ctx context.Context) *loaders {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if l, ok := ctx.Value(loadersKey{}).(*loaders); ok {
+		return l
+	}
+	return newLoaders(ctx)
+}
+
+// once calls fn only once per request for given key and returns its result
+func (l *loaders) once(key string, fn func() (interface{}, error)) (interface{}, error) {
+	l.mu.Lock()
+	entry, ok := l.memo[key]
+	metrics.CacheLookup("request", ok)
+	if !ok {
+		entry = &memoEntry{}
+		l.memo[key] = entry
+	}
+	l.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = fn()
+	})
+	return entry.value, entry.err
+}
+
+// loadFeeds returns thunk resolving system feeds, batched with other systems
+func loadFeeds(ctx context.Context, systemID string) func() (interface{}, error) {
+	return loadersFrom(ctx).feeds.load(systemID)
+}
+
+// loadLanguages returns thunk resolving languages of system feeds sorted alphabetically
+func loadLanguages(ctx context.Context, systemID string) func() (interface{}, error) {
+	thunk := loadFeeds(ctx, systemID)
+
+	return func() (interface{}, error) {
+		v, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+
+		feeds, _ := v.([]structs.Feed)
+		return feedsLanguages(feeds), nil
+	}
+}
+
+func feedsLanguages(feeds []structs.Feed) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, feed := range feeds {
+		if !seen[feed.Language] {
+			seen[feed.Language] = true
+			result = append(result, feed.Language)
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// Functions below load upstream feeds once per request,
+// as the same feed is often needed for every object in a list
+// (e.g. system regions for every station).
+// Values are keyed by negotiated feed language, so e.g. "fr-CA" and "fr" share one load.
+
+func requestStations(ctx context.Context, systemID, lang string) ([]station, error) {
+	lang, err := feedLanguage(ctx, systemID, "station_information", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := loadersFrom(ctx).once("stations:"+systemID+":"+lang, func() (interface{}, error) {
+		return getStations(ctx, systemID, lang)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]station), nil
+}
+
+func requestRegions(ctx context.Context, systemID, lang string) ([]region, error) {
+	lang, err := feedLanguage(ctx, systemID, "system_regions", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := loadersFrom(ctx).once("regions:"+systemID+":"+lang, func() (interface{}, error) {
+		return getRegions(ctx, systemID, lang)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]region), nil
+}
@@ -0,0 +1,110 @@
+package gbfs
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/relay"
+)
+
+// Types of objects that can be fetched with node query
+const (
+	nodeTypeSystem  = "System"
+	nodeTypeStation = "Station"
+	nodeTypeVehicle = "Vehicle"
+)
+
+// toGlobalID encodes object type, system ID and object ID into ID
+// unique across all types and systems, objectID is empty for systems
+func toGlobalID(typeName, systemID, objectID string) string {
+	if objectID == "" {
+		return relay.ToGlobalID(typeName, systemID)
+	}
+	return relay.ToGlobalID(typeName, systemID+":"+objectID)
+}
+
+// fromGlobalID decodes ID created by toGlobalID.
+// relay.FromGlobalID can not be used as object IDs may contain colons.
+func fromGlobalID(id string) (typeName, systemID, objectID string, err error) {
+	b, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid ID %q", id)
+	}
+
+	typeName, rest, ok := strings.Cut(string(b), ":")
+	if !ok || rest == "" {
+		return "", "", "", fmt.Errorf("invalid ID %q", id)
+	}
+
+	switch typeName {
+	case nodeTypeSystem:
+		return typeName, rest, "", nil
+	case nodeTypeStation, nodeTypeVehicle:
+		// object IDs may contain colons, so only the first one separates system ID
+		systemID, objectID, ok := strings.Cut(rest, ":")
+		if ok && systemID != "" && objectID != "" {
+			return typeName, systemID, objectID, nil
+		}
+	}
+
+	return "", "", "", fmt.Errorf("invalid ID %q", id)
+}
+
+// systemIDFromArg returns system ID from argument
+// that can be either system ID or system global ID
+func systemIDFromArg(id string) string {
+	typeName, systemID, _, err := fromGlobalID(id)
+	if err != nil || typeName != nodeTypeSystem {
+		return id
+	}
+	return systemID
+}
+
+// fetchNode returns object by its global ID
+func fetchNode(ctx context.Context, id, lang string) (interface{}, error) {
+	typeName, systemID, objectID, err := fromGlobalID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typeName {
+	case nodeTypeSystem:
+		return RedisClient.WithContext(ctx).GetSystem(systemID)
+	case nodeTypeStation:
+		return findStation(ctx, systemID, objectID, lang)
+	case nodeTypeVehicle:
+		return findVehicle(ctx, systemID, objectID, lang)
+	}
+
+	return nil, fmt.Errorf("unknown type %q", typeName)
+}
+
+func findStation(ctx context.Context, systemID, stationID, lang string) (interface{}, error) {
+	stations, err := getStations(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range stations {
+		if string(s.ID) == stationID {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func findVehicle(ctx context.Context, systemID, vehicleID, lang string) (interface{}, error) {
+	vehicles, _, err := loadVehicles(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range vehicles {
+		if string(v.BikeID) == vehicleID {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,52 @@
+package gbfs
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestGlobalIDRoundTrip(t *testing.T) {
+	tests := []struct {
+		typeName string
+		systemID string
+		objectID string
+	}{
+		{nodeTypeSystem, "bcycle_madison", ""},
+		{nodeTypeSystem, "urn:system:1", ""},
+		{nodeTypeStation, "bcycle_madison", "st:1:2"},
+		{nodeTypeStation, "nextbike_de", "station:"},
+		{nodeTypeVehicle, "lime", "v:9"},
+		{nodeTypeVehicle, "lime", "abc"},
+	}
+
+	for _, tt := range tests {
+		id := toGlobalID(tt.typeName, tt.systemID, tt.objectID)
+		typeName, systemID, objectID, err := fromGlobalID(id)
+		if err != nil {
+			t.Errorf("fromGlobalID(%q) error: %v", id, err)
+			continue
+		}
+		if typeName != tt.typeName || systemID != tt.systemID || objectID != tt.objectID {
+			t.Errorf("fromGlobalID(toGlobalID(%q, %q, %q)) = %q, %q, %q",
+				tt.typeName, tt.systemID, tt.objectID, typeName, systemID, objectID)
+		}
+	}
+}
+
+func TestFromGlobalIDInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	for _, id := range []string{
+		"not base64!",
+		encode("System"),
+		encode("System:"),
+		encode("Station:lime"),
+		encode("Station::1"),
+		encode("Vehicle:lime:"),
+		encode("Unknown:lime:1"),
+	} {
+		if _, _, _, err := fromGlobalID(id); err == nil {
+			t.Errorf("fromGlobalID(%q) error = nil, want error", id)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package gbfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/pkg/errors"
+)
+
+// maxPersistedQuerySize is the maximum size of query registered by client,
+// larger queries are executed but not stored in Redis
+const maxPersistedQuerySize = 16 << 10
+
+// persistedQueries resolves query text of requests sent with query hash
+type persistedQueries struct {
+	// allowList contains registered queries by their IDs
+	allowList map[string]string
+	// allowed contains texts of registered queries
+	allowed map[string]bool
+	// only disables queries not registered in allowList
+	only bool
+}
+
+// LoadPersistedQueries loads JSON file with queries by their IDs,
+// e.g. `{"<sha256 hash>": "query { ... }"}` as generated by relay-compiler
+func LoadPersistedQueries(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %q", path)
+	}
+
+	var queries map[string]string
+	if err := json.Unmarshal(b, &queries); err != nil {
+		return nil, errors.Wrapf(err, "decode %q", path)
+	}
+
+	return queries, nil
+}
+
+// OperationNames returns names of operations defined in queries
+func OperationNames(queries map[string]string) []string {
+	var names []string
+	for _, query := range queries {
+		doc, err := parser.Parse(parser.ParseParams{
+			Source: source.NewSource(&source.Source{Body: []byte(query)}),
+		})
+		if err != nil {
+			continue
+		}
+
+		for _, def := range doc.Definitions {
+			if op, ok := def.(*ast.OperationDefinition); ok && op.Name != nil {
+				names = append(names, op.Name.Value)
+			}
+		}
+	}
+	return names
+}
+
+func newPersistedQueries(allowList map[string]string, only bool) *persistedQueries {
+	allowed := make(map[string]bool, len(allowList))
+	for _, query := range allowList {
+		allowed[query] = true
+	}
+
+	return &persistedQueries{
+		allowList: allowList,
+		allowed:   allowed,
+		only:      only,
+	}
+}
+
+// resolve returns query text of the request, registering it
+// with Automatic Persisted Queries protocol if allowed
+func (pq *persistedQueries) resolve(ctx context.Context, req *graphQLRequest) (string, *gqlerrors.FormattedError) {
+	ext := req.Extensions.PersistedQuery
+
+	if ext == nil {
+		if pq.only && !pq.allowed[req.Query] {
+			err := graphQLError("PERSISTED_QUERY_NOT_ALLOWED", "Only persisted queries are allowed")
+			return "", &err
+		}
+		return req.Query, nil
+	}
+
+	if ext.Version != 1 {
+		err := graphQLError("PERSISTED_QUERY_NOT_SUPPORTED", "Unsupported persisted query version")
+		return "", &err
+	}
+
+	if req.Query == "" {
+		if query, ok := pq.allowList[ext.SHA256Hash]; ok {
+			return query, nil
+		}
+
+		if !pq.only {
+			query, err := RedisClient.WithContext(ctx).GetPersistedQuery(ext.SHA256Hash)
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to get persisted query", "hash", ext.SHA256Hash, "error", err)
+			}
+			if query != "" {
+				return query, nil
+			}
+		}
+
+		// message is defined by Automatic Persisted Queries protocol,
+		// clients retry with query text on this error
+		err := graphQLError("PERSISTED_QUERY_NOT_FOUND", "PersistedQueryNotFound")
+		return "", &err
+	}
+
+	if queryHash(req.Query) != ext.SHA256Hash {
+		err := graphQLError("PERSISTED_QUERY_HASH_MISMATCH", "Provided sha256Hash does not match query")
+		return "", &err
+	}
+
+	if pq.only {
+		if !pq.allowed[req.Query] {
+			err := graphQLError("PERSISTED_QUERY_NOT_ALLOWED", "Only persisted queries are allowed")
+			return "", &err
+		}
+		return req.Query, nil
+	}
+
+	if len(req.Query) > maxPersistedQuerySize {
+		slog.DebugContext(ctx, "Persisted query is too large to register", "hash", ext.SHA256Hash, "size", len(req.Query))
+		return req.Query, nil
+	}
+
+	if err := RedisClient.WithContext(ctx).WritePersistedQuery(ext.SHA256Hash, req.Query); err != nil {
+		slog.WarnContext(ctx, "Failed to write persisted query", "hash", ext.SHA256Hash, "error", err)
+	}
+
+	return req.Query, nil
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
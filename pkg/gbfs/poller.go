@@ -0,0 +1,230 @@
+package gbfs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+const (
+	// minPollInterval limits how often feeds with small or zero ttl are loaded
+	minPollInterval = 10 * time.Second
+	// maxPollInterval limits how long changes of feeds with large ttl are delayed
+	maxPollInterval = 5 * time.Minute
+	// subscriberBuffer is a number of changes batches kept for slow subscriber,
+	// newer batches are dropped when buffer is full
+	subscriberBuffer = 16
+)
+
+// entityChange is a change of a single feed entity between two polls,
+// Previous is nil for new entities, Current is nil for removed ones
+type entityChange struct {
+	ID       string
+	Previous interface{}
+	Current  interface{}
+}
+
+// feedLoader loads feed entities by their IDs and returns them with feed ttl in seconds
+type feedLoader func(ctx context.Context, systemID, lang string) (map[string]interface{}, int, error)
+
+type feedKey struct {
+	systemID string
+	feed     string
+	lang     string // available feed language negotiated with feedLanguage
+}
+
+type feedWatch struct {
+	subscribers map[chan []entityChange]struct{}
+	cancel      context.CancelFunc
+}
+
+// feedPoller loads every subscribed system feed once per feed ttl,
+// no matter how many subscribers it has, and sends changes to all of them
+type feedPoller struct {
+	mu      sync.Mutex
+	loaders map[string]feedLoader
+	watches map[feedKey]*feedWatch
+}
+
+var poller = newFeedPoller(map[string]feedLoader{
+	"station_status":   loadStationStatusEntities,
+	"free_bike_status": loadVehicleEntities,
+})
+
+func newFeedPoller(loaders map[string]feedLoader) *feedPoller {
+	return &feedPoller{
+		loaders: loaders,
+		watches: map[feedKey]*feedWatch{},
+	}
+}
+
+// subscribe returns channel with changes of the system feed in given language,
+// channel is closed when ctx is done
+func (p *feedPoller) subscribe(ctx context.Context, systemID, feed, lang string) (<-chan []entityChange, error) {
+	loader, ok := p.loaders[feed]
+	if !ok {
+		return nil, fmt.Errorf("feed %s can not be polled", feed)
+	}
+
+	// subscribers requesting different languages of the same feed share one poll
+	lang, err := feedLanguage(ctx, systemID, feed, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	key := feedKey{systemID: systemID, feed: feed, lang: lang}
+	ch := make(chan []entityChange, subscriberBuffer)
+
+	p.mu.Lock()
+	w, ok := p.watches[key]
+	if !ok {
+		pollCtx, cancel := context.WithCancel(context.Background())
+		w = &feedWatch{
+			subscribers: map[chan []entityChange]struct{}{},
+			cancel:      cancel,
+		}
+		p.watches[key] = w
+		go p.poll(pollCtx, w, key, loader)
+	}
+	w.subscribers[ch] = struct{}{}
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.unsubscribe(key, w, ch)
+	}()
+
+	return ch, nil
+}
+
+func (p *feedPoller) unsubscribe(key feedKey, w *feedWatch, ch chan []entityChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(w.subscribers, ch)
+	close(ch)
+
+	if len(w.subscribers) == 0 {
+		w.cancel()
+		delete(p.watches, key)
+	}
+}
+
+func (p *feedPoller) poll(ctx context.Context, w *feedWatch, key feedKey, loader feedLoader) {
+	var state map[string]interface{}
+
+	for {
+		entities, ttl, err := loader(ctx, key.systemID, key.lang)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to poll feed", "system", key.systemID, "feed", key.feed, "error", err)
+		} else {
+			if state != nil {
+				if changes := diffEntities(state, entities); len(changes) > 0 {
+					p.publish(w, key, changes)
+				}
+			}
+			state = entities
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval(ttl)):
+		}
+	}
+}
+
+func (p *feedPoller) publish(w *feedWatch, key feedKey, changes []entityChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for ch := range w.subscribers {
+		select {
+		case ch <- changes:
+		default:
+			slog.Warn("Subscriber is too slow, changes dropped", "system", key.systemID, "feed", key.feed)
+		}
+	}
+}
+
+func pollInterval(ttl int) time.Duration {
+	interval := time.Duration(ttl) * time.Second
+	if interval < minPollInterval {
+		return minPollInterval
+	}
+	if interval > maxPollInterval {
+		return maxPollInterval
+	}
+	return interval
+}
+
+// diffEntities returns changes between two feed states sorted by entity ID
+func diffEntities(prev, current map[string]interface{}) []entityChange {
+	var changes []entityChange
+
+	for id, c := range current {
+		p, ok := prev[id]
+		if !ok {
+			changes = append(changes, entityChange{ID: id, Current: c})
+			continue
+		}
+		if !reflect.DeepEqual(p, c) {
+			changes = append(changes, entityChange{ID: id, Previous: p, Current: c})
+		}
+	}
+
+	for id, p := range prev {
+		if _, ok := current[id]; !ok {
+			changes = append(changes, entityChange{ID: id, Previous: p})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ID < changes[j].ID
+	})
+
+	return changes
+}
+
+func loadStationStatusEntities(ctx context.Context, systemID, lang string) (map[string]interface{}, int, error) {
+	url, err := feedURL(ctx, systemID, "station_status", lang)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "station_status", url)
+	resp, err := Client.LoadStationStatus(url)
+	done(err)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "load station status %q", url)
+	}
+
+	result := make(map[string]interface{}, len(resp.Data.Stations))
+	for _, s := range resp.Data.Stations {
+		result[string(s.ID)] = s
+	}
+
+	return result, resp.TTL, nil
+}
+
+func loadVehicleEntities(ctx context.Context, systemID, lang string) (map[string]interface{}, int, error) {
+	vehicles, ttl, err := loadVehicles(ctx, systemID, lang)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make(map[string]interface{}, len(vehicles))
+	for _, v := range vehicles {
+		result[string(v.BikeID)] = v
+	}
+
+	return result, ttl, nil
+}
@@ -0,0 +1,152 @@
+package gbfs
+
+// https://github.com/NABSA/gbfs/blob/master/gbfs.md#system_pricing_plansjson
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+// PricingPlan is a normalized system pricing plan.
+// It's loaded without gbfs-go because pricing fields there
+// are not typed (price) or misspelled (interval).
+type PricingPlan struct {
+	ID            gbfs.ID          `json:"plan_id"`
+	URL           string           `json:"url,omitempty"`
+	Name          string           `json:"name"`
+	Currency      string           `json:"currency"`
+	Price         Decimal          `json:"price"`
+	IsTaxable     gbfs.Bool        `json:"is_taxable"`
+	Description   string           `json:"description"`
+	PerKmPricing  []PricingSegment `json:"per_km_pricing,omitempty"`  // added in v2.1-RC2
+	PerMinPricing []PricingSegment `json:"per_min_pricing,omitempty"` // added in v2.1-RC2
+}
+
+// PricingSegment describes rate charged every interval
+// of minutes or kilometers starting at start, until end (if set)
+type PricingSegment struct {
+	Start    float64  `json:"start"`
+	Rate     Decimal  `json:"rate"`
+	Interval float64  `json:"interval"`
+	End      *float64 `json:"end,omitempty"`
+}
+
+type pricingPlansResponse struct {
+	gbfs.Header
+	Data struct {
+		Plans []PricingPlan `json:"plans"`
+	} `json:"data"`
+}
+
+// LoadPricingPlans loads system_pricing_plans feed by URL
+func LoadPricingPlans(url string) ([]PricingPlan, error) {
+	var resp pricingPlansResponse
+	if err := loadJSON(url, &resp); err != nil {
+		return nil, errors.Wrapf(err, "load pricing plans %q", url)
+	}
+
+	return resp.Data.Plans, nil
+}
+
+func getPricingPlans(ctx context.Context, systemID, lang string) ([]PricingPlan, error) {
+	if !hasFeed(ctx, systemID, "system_pricing_plans") {
+		return []PricingPlan{}, nil
+	}
+
+	url, err := feedURL(ctx, systemID, "system_pricing_plans", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "system_pricing_plans", url)
+	plans, err := LoadPricingPlans(url)
+	done(err)
+	return plans, err
+}
+
+// TripCost is a breakdown of estimated trip cost
+type TripCost struct {
+	PlanID    gbfs.ID
+	Currency  string
+	BasePrice Decimal
+	Segments  []SegmentCost
+	Total     Decimal
+}
+
+// SegmentCost is the amount charged by a single pricing segment
+type SegmentCost struct {
+	Unit     string // "min" or "km"
+	Segment  PricingSegment
+	Charges  int // number of times the rate was charged
+	Subtotal Decimal
+}
+
+// EstimateTripCost applies plan base price
+// and per-minute and per-kilometer pricing segments to the trip
+func EstimateTripCost(plan PricingPlan, durationMinutes, distanceKm float64) TripCost {
+	cost := TripCost{
+		PlanID:    plan.ID,
+		Currency:  plan.Currency,
+		BasePrice: plan.Price,
+		Segments:  []SegmentCost{},
+		Total:     plan.Price,
+	}
+
+	for _, segment := range plan.PerMinPricing {
+		sc := segmentCost("min", segment, durationMinutes)
+		cost.Segments = append(cost.Segments, sc)
+		cost.Total = cost.Total.Add(sc.Subtotal)
+	}
+
+	for _, segment := range plan.PerKmPricing {
+		sc := segmentCost("km", segment, distanceKm)
+		cost.Segments = append(cost.Segments, sc)
+		cost.Total = cost.Total.Add(sc.Subtotal)
+	}
+
+	return cost
+}
+
+// segmentCost charges the rate at the beginning of every interval
+// that starts within the segment before the usage ends,
+// segment with zero interval is charged once
+func segmentCost(unit string, segment PricingSegment, usage float64) SegmentCost {
+	sc := SegmentCost{
+		Unit:    unit,
+		Segment: segment,
+	}
+
+	until := usage
+	if segment.End != nil && *segment.End < until {
+		until = *segment.End
+	}
+
+	if until <= segment.Start {
+		return sc
+	}
+
+	if segment.Interval <= 0 {
+		sc.Charges = 1
+	} else {
+		sc.Charges = int(math.Ceil((until - segment.Start) / segment.Interval))
+	}
+	sc.Subtotal = segment.Rate.Mul(int64(sc.Charges))
+	return sc
+}
+
+// FindPricingPlan returns plan by ID
+func FindPricingPlan(plans []PricingPlan, planID string) (*PricingPlan, error) {
+	for i := range plans {
+		if string(plans[i].ID) == planID {
+			return &plans[i], nil
+		}
+	}
+	return nil, fmt.Errorf("pricing plan %q not found", planID)
+}
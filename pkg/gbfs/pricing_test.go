@@ -0,0 +1,164 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSegmentCost(t *testing.T) {
+	end := func(v float64) *float64 { return &v }
+	rate := func(s string) Decimal {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		segment  PricingSegment
+		usage    float64
+		charges  int
+		subtotal string
+	}{
+		{
+			name:     "whole intervals",
+			segment:  PricingSegment{Start: 0, Rate: rate("0.5"), Interval: 1},
+			usage:    10,
+			charges:  10,
+			subtotal: "5.0",
+		},
+		{
+			name:     "partial interval is charged",
+			segment:  PricingSegment{Start: 0, Rate: rate("2"), Interval: 5},
+			usage:    11,
+			charges:  3,
+			subtotal: "6",
+		},
+		{
+			name:     "fractional rate is exact",
+			segment:  PricingSegment{Start: 0, Rate: rate("0.15"), Interval: 1},
+			usage:    3,
+			charges:  3,
+			subtotal: "0.45",
+		},
+		{
+			name:     "usage before start",
+			segment:  PricingSegment{Start: 30, Rate: rate("1"), Interval: 1},
+			usage:    20,
+			charges:  0,
+			subtotal: "0",
+		},
+		{
+			name:     "usage at start",
+			segment:  PricingSegment{Start: 30, Rate: rate("1"), Interval: 1},
+			usage:    30,
+			charges:  0,
+			subtotal: "0",
+		},
+		{
+			name:     "usage after end",
+			segment:  PricingSegment{Start: 10, Rate: rate("1"), Interval: 5, End: end(20)},
+			usage:    45,
+			charges:  2,
+			subtotal: "2",
+		},
+		{
+			name:     "usage before end",
+			segment:  PricingSegment{Start: 10, Rate: rate("1"), Interval: 5, End: end(20)},
+			usage:    12,
+			charges:  1,
+			subtotal: "1",
+		},
+		{
+			name:     "zero interval is charged once",
+			segment:  PricingSegment{Start: 0, Rate: rate("3"), Interval: 0},
+			usage:    45,
+			charges:  1,
+			subtotal: "3",
+		},
+		{
+			name:     "zero interval before start",
+			segment:  PricingSegment{Start: 60, Rate: rate("3"), Interval: 0},
+			usage:    45,
+			charges:  0,
+			subtotal: "0",
+		},
+		{
+			name:     "zero interval with end",
+			segment:  PricingSegment{Start: 10, Rate: rate("3"), Interval: 0, End: end(20)},
+			usage:    45,
+			charges:  1,
+			subtotal: "3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := segmentCost("min", tt.segment, tt.usage)
+			if sc.Charges != tt.charges {
+				t.Errorf("charges = %d, want %d", sc.Charges, tt.charges)
+			}
+			if sc.Subtotal.String() != tt.subtotal {
+				t.Errorf("subtotal = %s, want %s", sc.Subtotal, tt.subtotal)
+			}
+		})
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2.50", "2.50"},
+		{"2.5", "2.5"},
+		{"300", "300"},
+		{"0.125", "0.125"},
+		{".5", "0.5"},
+		{"3,20", "3.20"},
+		{"-1.05", "-1.05"},
+		{"1e-2", "0.01"},
+		{"0.1234567896", "0.123456790"},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.input)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q) error: %v", tt.input, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "-", "1.2.3", "abc", "1 000"} {
+		if _, err := ParseDecimal(input); err == nil {
+			t.Errorf("ParseDecimal(%q) error = nil, want error", input)
+		}
+	}
+}
+
+func TestEstimateTripCost(t *testing.T) {
+	var plan PricingPlan
+	err := json.Unmarshal([]byte(`{
+		"plan_id": "1",
+		"currency": "JPY",
+		"price": "150",
+		"per_min_pricing": [{"start": 0, "rate": 15, "interval": 1}],
+		"per_km_pricing": [{"start": 0, "rate": 0.125, "interval": 1}]
+	}`), &plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cost := EstimateTripCost(plan, 10, 3)
+	if got := cost.BasePrice.String(); got != "150" {
+		t.Errorf("base price = %s, want 150", got)
+	}
+	if got := cost.Total.String(); got != "300.375" {
+		t.Errorf("total = %s, want 300.375", got)
+	}
+}
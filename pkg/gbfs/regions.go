@@ -0,0 +1,154 @@
+package gbfs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+type region struct {
+	SystemID string
+	ID       gbfs.ID
+	Name     string
+
+	aggregates *regionAggregates // shared by regions of the same system
+}
+
+// regionCounts are totals of stations within the region
+type regionCounts struct {
+	StationsCount     int
+	Capacity          int
+	NumBikesAvailable uint
+	NumDocksAvailable uint
+}
+
+// regionAggregates loads stations information and status once,
+// when any of regions counts is requested
+type regionAggregates struct {
+	ctx      context.Context
+	systemID string
+	lang     string
+	once     sync.Once
+	counts   map[gbfs.ID]*regionCounts
+	err      error
+}
+
+func (a *regionAggregates) get(regionID gbfs.ID) (*regionCounts, error) {
+	a.once.Do(func() {
+		a.counts, a.err = countStationsByRegion(a.ctx, a.systemID, a.lang)
+	})
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	if counts, ok := a.counts[regionID]; ok {
+		return counts, nil
+	}
+	return &regionCounts{}, nil
+}
+
+func countStationsByRegion(ctx context.Context, systemID, lang string) (map[gbfs.ID]*regionCounts, error) {
+	stations, err := getStationInformation(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := getStationStatus(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	statusByID := make(map[gbfs.ID]gbfs.StationStatus, len(status))
+	for _, s := range status {
+		statusByID[s.ID] = s
+	}
+
+	result := map[gbfs.ID]*regionCounts{}
+	for _, station := range stations {
+		counts, ok := result[station.RegionID]
+		if !ok {
+			counts = &regionCounts{}
+			result[station.RegionID] = counts
+		}
+
+		counts.StationsCount++
+		counts.Capacity += station.Capacity
+
+		if s, ok := statusByID[station.ID]; ok {
+			counts.NumBikesAvailable += s.NumBikesAvailable
+			counts.NumDocksAvailable += s.NumDocksAvailable
+		}
+	}
+
+	return result, nil
+}
+
+func getRegions(ctx context.Context, systemID, lang string) ([]region, error) {
+	result := []region{}
+
+	if !hasFeed(ctx, systemID, "system_regions") {
+		return result, nil
+	}
+
+	url, err := feedURL(ctx, systemID, "system_regions", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "system_regions", url)
+	resp, err := Client.LoadSystemRegions(url)
+	done(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load system regions %q", url)
+	}
+
+	aggregates := &regionAggregates{ctx: ctx, systemID: systemID, lang: lang}
+	for _, r := range resp.Data.Regions {
+		result = append(result, region{
+			SystemID:   systemID,
+			ID:         r.ID,
+			Name:       r.Name,
+			aggregates: aggregates,
+		})
+	}
+
+	return result, nil
+}
+
+func findRegion(ctx context.Context, systemID, lang string, regionID gbfs.ID) (*region, error) {
+	if regionID == "" {
+		return nil, nil
+	}
+
+	regions, err := requestRegions(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range regions {
+		if regions[i].ID == regionID {
+			return &regions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func filterRegionsByIDs(regions []region, ids []string) []region {
+	byID := make(map[gbfs.ID]region, len(regions))
+	for _, r := range regions {
+		byID[r.ID] = r
+	}
+
+	result := []region{}
+	for _, id := range ids {
+		if r, ok := byID[gbfs.ID(id)]; ok {
+			result = append(result, r)
+		}
+	}
+	return result
+}
@@ -0,0 +1,219 @@
+package gbfs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/pkg/errors"
+)
+
+// persistedQueryExtension is Automatic Persisted Queries request extension
+type persistedQueryExtension struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+type requestExtensions struct {
+	PersistedQuery *persistedQueryExtension `json:"persistedQuery,omitempty"`
+}
+
+// graphQLRequest is GraphQL request sent with GET or POST
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+	Extensions    requestExtensions      `json:"extensions"`
+}
+
+// parseGraphQLRequest parses GraphQL request from URL query parameters,
+// JSON, form or application/graphql body
+func parseGraphQLRequest(r *http.Request) (*graphQLRequest, error) {
+	if r.Method != http.MethodPost {
+		return requestFromValues(r.URL.Query())
+	}
+
+	contentType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+
+	switch contentType {
+	case "application/graphql":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "read body")
+		}
+		return &graphQLRequest{Query: string(body)}, nil
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, errors.Wrap(err, "parse form")
+		}
+		return requestFromValues(r.PostForm)
+	}
+
+	var req struct {
+		graphQLRequest
+		Variables json.RawMessage `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(err, "decode body")
+	}
+
+	variables, err := parseVariables(req.Variables)
+	if err != nil {
+		return nil, err
+	}
+	req.graphQLRequest.Variables = variables
+
+	return &req.graphQLRequest, nil
+}
+
+func requestFromValues(values url.Values) (*graphQLRequest, error) {
+	req := &graphQLRequest{
+		Query:         values.Get("query"),
+		OperationName: values.Get("operationName"),
+	}
+
+	if v := values.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+			return nil, errors.Wrap(err, "decode variables")
+		}
+	}
+
+	if v := values.Get("extensions"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+			return nil, errors.Wrap(err, "decode extensions")
+		}
+	}
+
+	return req, nil
+}
+
+// parseVariables decodes variables sent either as JSON object or as string with JSON object
+func parseVariables(raw json.RawMessage) (map[string]interface{}, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		if encoded == "" {
+			return nil, nil
+		}
+		raw = json.RawMessage(encoded)
+	}
+
+	var variables map[string]interface{}
+	if err := json.Unmarshal(raw, &variables); err != nil {
+		return nil, errors.Wrap(err, "decode variables")
+	}
+	return variables, nil
+}
+
+// parseOperation parses query and returns operation to execute,
+// nil is returned for invalid queries as they are rejected by GraphQL validation
+func parseOperation(query, operationName string) (*ast.Document, *ast.OperationDefinition) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(query)}),
+	})
+	if err != nil {
+		return nil, nil
+	}
+
+	var operations []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			operations = append(operations, op)
+		}
+	}
+
+	if len(operations) != 1 {
+		return doc, nil
+	}
+	return doc, operations[0]
+}
+
+func documentFragments(doc *ast.Document) map[string]*ast.FragmentDefinition {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	return fragments
+}
+
+// hasIntrospection reports whether operation selects __schema or __type fields
+func hasIntrospection(doc *ast.Document, op *ast.OperationDefinition) bool {
+	fragments := documentFragments(doc)
+	visited := map[string]bool{}
+
+	var walk func(set *ast.SelectionSet) bool
+	walk = func(set *ast.SelectionSet) bool {
+		if set == nil {
+			return false
+		}
+
+		for _, selection := range set.Selections {
+			switch s := selection.(type) {
+			case *ast.Field:
+				if s.Name.Value == "__schema" || s.Name.Value == "__type" || walk(s.SelectionSet) {
+					return true
+				}
+			case *ast.InlineFragment:
+				if walk(s.SelectionSet) {
+					return true
+				}
+			case *ast.FragmentSpread:
+				fragment, ok := fragments[s.Name.Value]
+				if !ok || visited[s.Name.Value] {
+					continue
+				}
+				visited[s.Name.Value] = true
+				if walk(fragment.SelectionSet) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	return walk(op.SelectionSet)
+}
+
+// graphQLError returns GraphQL error with code in extensions
+func graphQLError(code, message string) gqlerrors.FormattedError {
+	return gqlerrors.FormattedError{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
+
+// writeGraphQLErrors writes GraphQL response with errors only
+func writeGraphQLErrors(w http.ResponseWriter, errs ...gqlerrors.FormattedError) {
+	writeGraphQLResult(w, &graphql.Result{Errors: errs})
+}
+
+func writeGraphQLResult(w http.ResponseWriter, result *graphql.Result) {
+	b, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		slog.Warn("Failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,65 @@
+package gbfs
+
+import (
+	"context"
+	"github.com/graphql-go/graphql"
+
+	"github.com/chuhlomin/gbfs-go"
+)
+
+// station is a station information of a specific system
+type station struct {
+	gbfs.StationInformation
+	SystemID string
+}
+
+// Resolve resolves station fields without custom resolvers
+// from embedded station information
+func (s station) Resolve(p graphql.ResolveParams) (interface{}, error) {
+	p.Source = s.StationInformation
+	return graphql.DefaultResolveFn(p)
+}
+
+func getStations(ctx context.Context, systemID, lang string) ([]station, error) {
+	stations, err := getStationInformation(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]station, 0, len(stations))
+	for _, s := range stations {
+		result = append(result, station{StationInformation: s, SystemID: systemID})
+	}
+	return result, nil
+}
+
+func filterStationsByRegion(stations []gbfs.StationInformation, regionID string) []gbfs.StationInformation {
+	result := []gbfs.StationInformation{}
+	for _, s := range stations {
+		if string(s.RegionID) == regionID {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// filterStationStatusByRegion keeps status of stations located in the region
+func filterStationStatusByRegion(ctx context.Context, systemID, lang string, status []gbfs.StationStatus, regionID string) ([]gbfs.StationStatus, error) {
+	stations, err := getStationInformation(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	inRegion := map[gbfs.ID]struct{}{}
+	for _, s := range filterStationsByRegion(stations, regionID) {
+		inRegion[s.ID] = struct{}{}
+	}
+
+	result := []gbfs.StationStatus{}
+	for _, s := range status {
+		if _, ok := inRegion[s.ID]; ok {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
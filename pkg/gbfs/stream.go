@@ -0,0 +1,390 @@
+package gbfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chuhlomin/gbfs-go"
+)
+
+const (
+	// streamBufferSize is a number of recent events kept for resuming with Last-Event-ID
+	streamBufferSize = 1000
+	// streamIdleTimeout is how long system stream keeps polling after last client disconnected,
+	// so reconnecting clients can resume without missing events
+	streamIdleTimeout = 2 * time.Minute
+	// streamKeepAlive is an interval of comments sent to keep idle connections open
+	streamKeepAlive = 30 * time.Second
+	// streamClientBuffer is a number of events queued for a client,
+	// slow clients are disconnected and expected to resume with Last-Event-ID
+	streamClientBuffer = 256
+)
+
+// countChange is a change of station counter, From is nil for new stations
+type countChange struct {
+	From *uint `json:"from,omitempty"`
+	To   uint  `json:"to"`
+}
+
+// flagChange is a change of station flag, From is nil for new stations
+type flagChange struct {
+	From *bool `json:"from,omitempty"`
+	To   bool  `json:"to"`
+}
+
+// stationDelta describes how station status changed since previous snapshot,
+// only changed fields are set
+type stationDelta struct {
+	StationID         string       `json:"stationID"`
+	Removed           bool         `json:"removed,omitempty"`
+	NumBikesAvailable *countChange `json:"numBikesAvailable,omitempty"`
+	NumBikesDisabled  *countChange `json:"numBikesDisabled,omitempty"`
+	NumDocksAvailable *countChange `json:"numDocksAvailable,omitempty"`
+	IsInstalled       *flagChange  `json:"isInstalled,omitempty"`
+	IsRenting         *flagChange  `json:"isRenting,omitempty"`
+	IsReturning       *flagChange  `json:"isReturning,omitempty"`
+	LastReported      *time.Time   `json:"lastReported,omitempty"`
+}
+
+type streamEvent struct {
+	ID   string
+	Name string
+	Data []byte
+}
+
+// stationStream polls station status of a single system
+// and keeps recent events for all connected clients
+type stationStream struct {
+	systemID string
+	// epoch distinguishes event IDs of streams started at different times
+	epoch string
+
+	mu      sync.Mutex
+	seq     int
+	buffer  []streamEvent
+	clients map[chan streamEvent]struct{}
+	cancel  context.CancelFunc
+	idle    *time.Timer
+}
+
+type stationStreams struct {
+	mu      sync.Mutex
+	streams map[string]*stationStream
+}
+
+var streams = &stationStreams{streams: map[string]*stationStream{}}
+
+// HandlerStationsStream streams station status changes as Server-Sent Events
+func HandlerStationsStream() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		systemID := r.URL.Query().Get("systemID")
+		if systemID == "" {
+			http.Error(w, "Missing systemID parameter", 400)
+			return
+		}
+
+		if _, err := feedURL(r.Context(), systemID, "station_status", ""); err != nil {
+			http.Error(w, err.Error(), 404)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported", 500)
+			return
+		}
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("lastEventID")
+		}
+
+		stream, ch, missed, err := streams.join(systemID, lastEventID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to start stream: %v", err), 500)
+			return
+		}
+		defer streams.leave(stream, ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		if missed == nil {
+			// events after lastEventID are no longer available,
+			// client has to reload stations status
+			writeStreamEvent(w, streamEvent{Name: "reset", Data: []byte("{}")})
+		}
+		for _, event := range missed {
+			writeStreamEvent(w, event)
+		}
+		flusher.Flush()
+
+		// stream outlives server timeouts; expired read deadline
+		// would make server cancel request context
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Time{})
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-shutdown:
+				return
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				writeStreamEvent(w, event)
+				flusher.Flush()
+			case <-keepAlive.C:
+				_, _ = w.Write([]byte(": ping\n\n"))
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+func writeStreamEvent(w http.ResponseWriter, event streamEvent) {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + event.ID + "\n")
+	}
+	b.WriteString("event: " + event.Name + "\n")
+	b.WriteString("data: ")
+	b.Write(event.Data)
+	b.WriteString("\n\n")
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// join connects client to the system stream, starting it if needed,
+// and returns events missed since lastEventID.
+// Missed events are nil if lastEventID is set but can not be resumed from.
+func (s *stationStreams) join(systemID, lastEventID string) (*stationStream, chan streamEvent, []streamEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, ok := s.streams[systemID]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		changes, err := poller.subscribe(ctx, systemID, "station_status", "")
+		if err != nil {
+			cancel()
+			return nil, nil, nil, err
+		}
+
+		stream = &stationStream{
+			systemID: systemID,
+			epoch:    strconv.FormatInt(time.Now().UnixNano(), 36),
+			clients:  map[chan streamEvent]struct{}{},
+			cancel:   cancel,
+		}
+		s.streams[systemID] = stream
+		go stream.run(changes)
+	}
+
+	ch, missed := stream.join(lastEventID)
+	return stream, ch, missed, nil
+}
+
+// leave disconnects client and stops the stream
+// if no clients reconnect during streamIdleTimeout
+func (s *stationStreams) leave(stream *stationStream, ch chan streamEvent) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if _, ok := stream.clients[ch]; ok {
+		delete(stream.clients, ch)
+		close(ch)
+	}
+
+	if len(stream.clients) > 0 || stream.idle != nil {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(streamIdleTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+
+		// client joined and possibly left again after the timer fired
+		if len(stream.clients) > 0 || stream.idle != timer {
+			return
+		}
+
+		stream.cancel()
+		delete(s.streams, stream.systemID)
+	})
+	stream.idle = timer
+}
+
+func (s *stationStream) join(lastEventID string) (chan streamEvent, []streamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.idle != nil {
+		s.idle.Stop()
+		s.idle = nil
+	}
+
+	ch := make(chan streamEvent, streamClientBuffer)
+	s.clients[ch] = struct{}{}
+
+	return ch, s.eventsAfter(lastEventID)
+}
+
+// eventsAfter returns buffered events published after event with given ID
+func (s *stationStream) eventsAfter(lastEventID string) []streamEvent {
+	if lastEventID == "" {
+		return []streamEvent{}
+	}
+
+	parts := strings.SplitN(lastEventID, "-", 2)
+	if len(parts) != 2 || parts[0] != s.epoch {
+		return nil
+	}
+
+	seq, err := strconv.Atoi(parts[1])
+	if err != nil || seq > s.seq {
+		return nil
+	}
+
+	// buffer holds events with sequence numbers from s.seq-len(s.buffer)+1 to s.seq
+	first := s.seq - len(s.buffer) + 1
+	if seq < first-1 {
+		return nil
+	}
+
+	result := make([]streamEvent, len(s.buffer)-(seq-first+1))
+	copy(result, s.buffer[seq-first+1:])
+	return result
+}
+
+func (s *stationStream) run(changes <-chan []entityChange) {
+	for batch := range changes {
+		for _, c := range batch {
+			delta := diffStationStatus(c)
+			if delta == nil {
+				continue
+			}
+
+			data, err := json.Marshal(delta)
+			if err != nil {
+				slog.Warn("Failed to marshal station delta", "system", s.systemID, "station", c.ID, "error", err)
+				continue
+			}
+
+			s.publish("station", data)
+		}
+	}
+}
+
+func (s *stationStream) publish(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	event := streamEvent{
+		ID:   s.epoch + "-" + strconv.Itoa(s.seq),
+		Name: name,
+		Data: data,
+	}
+
+	s.buffer = append(s.buffer, event)
+	if len(s.buffer) > streamBufferSize {
+		s.buffer = s.buffer[len(s.buffer)-streamBufferSize:]
+	}
+
+	for ch := range s.clients {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Stream client is too slow, disconnecting", "system", s.systemID)
+			delete(s.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// diffStationStatus returns station delta for the change,
+// nil is returned if none of the streamed fields changed
+func diffStationStatus(c entityChange) *stationDelta {
+	delta := &stationDelta{StationID: c.ID}
+
+	current, ok := c.Current.(gbfs.StationStatus)
+	if !ok {
+		delta.Removed = true
+		return delta
+	}
+
+	previous, existed := c.Previous.(gbfs.StationStatus)
+	changed := !existed
+
+	counts := []struct {
+		field    **countChange
+		from, to uint
+	}{
+		{&delta.NumBikesAvailable, previous.NumBikesAvailable, current.NumBikesAvailable},
+		{&delta.NumBikesDisabled, previous.NumBikesDisabled, current.NumBikesDisabled},
+		{&delta.NumDocksAvailable, previous.NumDocksAvailable, current.NumDocksAvailable},
+	}
+	for _, count := range counts {
+		if existed && count.from == count.to {
+			continue
+		}
+		change := &countChange{To: count.to}
+		if existed {
+			from := count.from
+			change.From = &from
+		}
+		*count.field = change
+		changed = true
+	}
+
+	flags := []struct {
+		field    **flagChange
+		from, to gbfs.Bool
+	}{
+		{&delta.IsInstalled, previous.IsInstalled, current.IsInstalled},
+		{&delta.IsRenting, previous.IsRenting, current.IsRenting},
+		{&delta.IsReturning, previous.IsReturning, current.IsReturning},
+	}
+	for _, flag := range flags {
+		if existed && flag.from == flag.to {
+			continue
+		}
+		change := &flagChange{To: bool(flag.to)}
+		if existed {
+			from := bool(flag.from)
+			change.From = &from
+		}
+		*flag.field = change
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if current.LastReported.Unix() > 0 {
+		lastReported := current.LastReported.Time()
+		delta.LastReported = &lastReported
+	}
+
+	return delta
+}
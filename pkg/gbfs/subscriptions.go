@@ -0,0 +1,372 @@
+package gbfs
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/geo"
+)
+
+// WebSocket subprotocols supported for GraphQL subscriptions:
+// graphql-transport-ws is used by graphql-ws library,
+// graphql-ws is a legacy one used by subscriptions-transport-ws library
+const (
+	protocolTransportWS = "graphql-transport-ws"
+	protocolLegacyWS    = "graphql-ws"
+)
+
+// Close codes defined by graphql-transport-ws protocol
+const (
+	closeBadRequest      = 4400
+	closeUnauthorized    = 4401
+	closeSubscriberExist = 4409
+)
+
+// closeTimeout is how long client has to confirm closing of connection on server shutdown
+const closeTimeout = 5 * time.Second
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{protocolTransportWS, protocolLegacyWS},
+	// API is public and read-only, so connections from any origin are allowed
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type subscribePayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+	Extensions    requestExtensions      `json:"extensions"`
+}
+
+// wsSession is a single WebSocket connection with its running subscriptions
+type wsSession struct {
+	// ctx of upgrade request carries its request ID and span
+	ctx         context.Context
+	conn        *websocket.Conn
+	legacy      bool
+	initialized bool
+	// lang is Accept-Language of upgrade request
+	lang string
+	// persisted resolves queries of subscribe messages
+	persisted *persistedQueries
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	operations map[string]context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+func handleSubscriptions(w http.ResponseWriter, r *http.Request, persisted *persistedQueries) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Failed to upgrade connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	s := &wsSession{
+		ctx:        r.Context(),
+		conn:       conn,
+		legacy:     conn.Subprotocol() == protocolLegacyWS,
+		lang:       r.Header.Get("Accept-Language"),
+		persisted:  persisted,
+		operations: map[string]context.CancelFunc{},
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-shutdown:
+			s.close(websocket.CloseGoingAway, "Server is shutting down")
+			// wait for client to confirm closing, then stop reading
+			_ = conn.SetReadDeadline(time.Now().Add(closeTimeout))
+		case <-done:
+		}
+	}()
+
+	s.run()
+}
+
+func (s *wsSession) run() {
+	defer func() {
+		s.mu.Lock()
+		for _, cancel := range s.operations {
+			cancel()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+	}()
+
+	for {
+		var msg wsMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.WarnContext(s.ctx, "Failed to read WebSocket message", "error", err)
+			}
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			s.initialized = true
+			s.write(wsMessage{Type: "connection_ack"})
+		case "ping":
+			s.write(wsMessage{Type: "pong"})
+		case "pong":
+		case "subscribe", "start":
+			if !s.initialized {
+				s.close(closeUnauthorized, "Unauthorized")
+				return
+			}
+			if !s.start(msg) {
+				return
+			}
+		case "complete", "stop":
+			s.stop(msg.ID)
+		case "connection_terminate":
+			return
+		default:
+			s.close(closeBadRequest, "Unknown message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// start runs subscription operation,
+// returns false if connection was closed because of invalid message
+func (s *wsSession) start(msg wsMessage) bool {
+	var payload subscribePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || msg.ID == "" {
+		s.close(closeBadRequest, "Invalid message")
+		return false
+	}
+
+	query, qerr := s.persisted.resolve(s.ctx, &graphQLRequest{
+		Query:         payload.Query,
+		OperationName: payload.OperationName,
+		Extensions:    payload.Extensions,
+	})
+	if qerr != nil {
+		s.writePayload(msg.ID, "error", []gqlerrors.FormattedError{*qerr})
+		return true
+	}
+
+	if doc, op := parseOperation(query, payload.OperationName); op != nil {
+		if err := checkQueryComplexity(Schema, doc, op, payload.Variables); err != nil {
+			s.writePayload(msg.ID, "error", []gqlerrors.FormattedError{err.(*complexityError).formatted()})
+			return true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(withLanguage(context.WithoutCancel(s.ctx), s.lang))
+
+	s.mu.Lock()
+	if _, ok := s.operations[msg.ID]; ok {
+		s.mu.Unlock()
+		cancel()
+		s.close(closeSubscriberExist, "Subscriber for "+msg.ID+" already exists")
+		return false
+	}
+	s.operations[msg.ID] = cancel
+	s.mu.Unlock()
+
+	results := graphql.Subscribe(graphql.Params{
+		Schema:         Schema,
+		RequestString:  query,
+		VariableValues: payload.Variables,
+		OperationName:  payload.OperationName,
+		Context:        ctx,
+	})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.stop(msg.ID)
+
+		failed := false
+		for result := range results {
+			// results are drained until channel is closed after cancellation,
+			// otherwise subscription goroutine would block forever
+			if failed {
+				continue
+			}
+			if result.Data == nil && result.HasErrors() {
+				s.writePayload(msg.ID, "error", result.Errors)
+				s.stop(msg.ID)
+				failed = true
+				continue
+			}
+			if s.legacy {
+				s.writePayload(msg.ID, "data", result)
+			} else {
+				s.writePayload(msg.ID, "next", result)
+			}
+		}
+
+		if ctx.Err() == nil {
+			s.write(wsMessage{ID: msg.ID, Type: "complete"})
+		}
+	}()
+
+	return true
+}
+
+func (s *wsSession) stop(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cancel, ok := s.operations[id]; ok {
+		cancel()
+		delete(s.operations, id)
+	}
+}
+
+func (s *wsSession) writePayload(id, messageType string, payload interface{}) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(s.ctx, "Failed to marshal payload", "type", messageType, "error", err)
+		return
+	}
+
+	s.write(wsMessage{ID: id, Type: messageType, Payload: b})
+}
+
+func (s *wsSession) write(msg wsMessage) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.conn.WriteJSON(msg); err != nil {
+		slog.WarnContext(s.ctx, "Failed to write WebSocket message", "error", err)
+	}
+}
+
+func (s *wsSession) close(code int, reason string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_ = s.conn.WriteMessage(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+	)
+}
+
+type vehiclesChange struct {
+	Updated []interface{}
+	Removed []string
+}
+
+// subscribeStationStatus returns channel with lists of changed station statuses,
+// stationIDs limits stations to watch, all stations are watched if it's empty
+func subscribeStationStatus(ctx context.Context, systemID, lang string, stationIDs []string) (interface{}, error) {
+	if _, err := feedURL(ctx, systemID, "station_status", lang); err != nil {
+		return nil, err
+	}
+
+	changes, err := poller.subscribe(ctx, systemID, "station_status", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[string]bool, len(stationIDs))
+	for _, id := range stationIDs {
+		watched[id] = true
+	}
+
+	return relayChanges(ctx, changes, func(batch []entityChange) interface{} {
+		var updated []interface{}
+		for _, c := range batch {
+			if c.Current == nil || (len(watched) > 0 && !watched[c.ID]) {
+				continue
+			}
+			updated = append(updated, c.Current)
+		}
+
+		if len(updated) == 0 {
+			return nil
+		}
+		return updated
+	}), nil
+}
+
+// subscribeVehicles returns channel with vehicles changes,
+// vehicles moved out of bbox are reported as removed
+func subscribeVehicles(ctx context.Context, systemID, lang string, bbox *geo.BBox) (interface{}, error) {
+	if _, err := feedURL(ctx, systemID, "free_bike_status", lang); err != nil {
+		return nil, err
+	}
+
+	changes, err := poller.subscribe(ctx, systemID, "free_bike_status", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	inside := func(v interface{}) bool {
+		if v == nil {
+			return false
+		}
+		if bbox == nil {
+			return true
+		}
+		vv := v.(vehicle)
+		return bbox.Contains(vv.Lon, vv.Lat)
+	}
+
+	return relayChanges(ctx, changes, func(batch []entityChange) interface{} {
+		result := vehiclesChange{}
+		for _, c := range batch {
+			switch {
+			case inside(c.Current):
+				result.Updated = append(result.Updated, c.Current)
+			case inside(c.Previous):
+				result.Removed = append(result.Removed, toGlobalID(nodeTypeVehicle, systemID, c.ID))
+			}
+		}
+
+		if len(result.Updated) == 0 && len(result.Removed) == 0 {
+			return nil
+		}
+		return result
+	}), nil
+}
+
+// relayChanges converts batches of feed changes into subscription payloads,
+// batches converted to nil are skipped
+func relayChanges(ctx context.Context, changes <-chan []entityChange, convert func([]entityChange) interface{}) chan interface{} {
+	result := make(chan interface{})
+
+	go func() {
+		defer close(result)
+
+		for batch := range changes {
+			payload := convert(batch)
+			if payload == nil {
+				continue
+			}
+
+			select {
+			case result <- payload:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return result
+}
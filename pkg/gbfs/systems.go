@@ -0,0 +1,364 @@
+package gbfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/geo"
+	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+)
+
+// coverageMargin is the distance from the outermost stations
+// that is still considered to be served by the system
+const coverageMargin = 500 // meters
+
+// geofencingMargin limits systems checked by geofencing zones,
+// zones may extend beyond the stations area
+const geofencingMargin = 10000 // meters
+
+// systemHealthUnknown is health of systems not checked by writer yet
+const systemHealthUnknown = "unknown"
+
+// Orders of systems query
+const (
+	systemsOrderName          = "name"
+	systemsOrderCountry       = "country"
+	systemsOrderStationsCount = "stationsCount"
+)
+
+// systemsFilter selects systems returned by systems query,
+// empty fields match all systems
+type systemsFilter struct {
+	Countries []string
+	Feeds     []string // names of feeds system must publish
+	Versions  []string // GBFS versions, "2" matches any 2.x version
+	Health    []string
+	IsEnabled *bool
+	Search    string
+}
+
+// match reports whether system matches filter, feeds are system feeds used for Feeds filter
+func (f systemsFilter) match(system *structs.System, status *structs.SystemStatus, feeds []structs.Feed) bool {
+	if len(f.Countries) > 0 && !containsFold(f.Countries, system.CountryCode) {
+		return false
+	}
+
+	for _, feed := range f.Feeds {
+		if !containsFeed(feeds, feed) {
+			return false
+		}
+	}
+
+	if len(f.Versions) > 0 && (status == nil || !matchVersion(f.Versions, status.Version)) {
+		return false
+	}
+
+	if len(f.Health) > 0 && !containsFold(f.Health, systemHealth(status)) {
+		return false
+	}
+
+	if f.IsEnabled != nil && system.IsEnabled != *f.IsEnabled {
+		return false
+	}
+
+	if f.Search != "" {
+		text := normalizeText(system.Name + " " + system.Location + " " + system.ID)
+		for _, word := range strings.Fields(normalizeText(f.Search)) {
+			if !strings.Contains(text, word) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// getSystems returns systems matching filter, sorted by order if it's set
+func getSystems(ctx context.Context, filter systemsFilter, order string, descending bool) ([]*structs.System, error) {
+	systems, err := RedisClient.GetSystems()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := RedisClient.GetSystemStatuses()
+	if err != nil {
+		return nil, err
+	}
+
+	// feeds of all systems are resolved at once,
+	// so systems missing in memory cache don't cost a Redis round trip each
+	var feeds map[string][]structs.Feed
+	if len(filter.Feeds) > 0 {
+		systemIDs := make([]string, len(systems))
+		for i, system := range systems {
+			systemIDs[i] = system.ID
+		}
+
+		feeds, err = RedisClient.WithContext(ctx).GetFeedsBatch(systemIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := []*structs.System{}
+	for _, system := range systems {
+		if filter.match(system, statuses[system.ID], feeds[system.ID]) {
+			result = append(result, system)
+		}
+	}
+
+	if order != "" {
+		sortSystems(result, statuses, order, descending)
+	}
+
+	return result, nil
+}
+
+// sortSystems sorts systems by order, systems with equal values are sorted by name
+func sortSystems(systems []*structs.System, statuses map[string]*structs.SystemStatus, order string, descending bool) {
+	stationsCount := func(system *structs.System) int {
+		if status, ok := statuses[system.ID]; ok {
+			return status.StationsCount
+		}
+		return 0
+	}
+
+	names := make(map[string]string, len(systems))
+	for _, system := range systems {
+		names[system.ID] = normalizeText(system.Name)
+	}
+
+	compare := func(a, b *structs.System) int {
+		switch order {
+		case systemsOrderCountry:
+			if c := strings.Compare(a.CountryCode, b.CountryCode); c != 0 {
+				return c
+			}
+		case systemsOrderStationsCount:
+			if c := stationsCount(a) - stationsCount(b); c != 0 {
+				return c
+			}
+		}
+		if c := strings.Compare(names[a.ID], names[b.ID]); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	}
+
+	sort.Slice(systems, func(i, j int) bool {
+		if descending {
+			return compare(systems[i], systems[j]) > 0
+		}
+		return compare(systems[i], systems[j]) < 0
+	})
+}
+
+func systemHealth(status *structs.SystemStatus) string {
+	if status == nil || status.Health == "" {
+		return systemHealthUnknown
+	}
+	return status.Health
+}
+
+// matchVersion reports whether version matches any of versions,
+// versions without minor part match all minor versions
+func matchVersion(versions []string, version string) bool {
+	for _, v := range versions {
+		if version == v || strings.HasPrefix(version, v+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeText returns lowercased text without diacritics for search, e.g. "Montréal" -> "montreal"
+func normalizeText(text string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, text)
+	if err != nil {
+		result = text
+	}
+	return strings.ToLower(result)
+}
+
+func HandlerSystemsAt() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			http.Error(w, fmt.Sprintf("Invalid lat %q", query.Get("lat")), 400)
+			return
+		}
+
+		lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			http.Error(w, fmt.Sprintf("Invalid lon %q", query.Get("lon")), 400)
+			return
+		}
+
+		systems, err := getSystemsAt(r.Context(), lon, lat)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get systems: %v", err), 500)
+			return
+		}
+
+		b, err := json.Marshal(systems)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to marshal systems: %v", err), 500)
+			return
+		}
+
+		if writeContentCacheHeaders(w, r, b, systemsMaxAge) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(b)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to write response: %v", err), 500)
+		}
+	})
+}
+
+// getSystemsAt returns systems serving the location, closest first.
+// System geofencing zones are used when available,
+// otherwise location is matched against the area computed from stations.
+// Systems without stations are matched by geofencing zones only.
+func getSystemsAt(ctx context.Context, lon, lat float64) ([]*structs.System, error) {
+	systems, err := RedisClient.GetSystems()
+	if err != nil {
+		return nil, err
+	}
+
+	coverages, err := RedisClient.GetCoverages()
+	if err != nil {
+		return nil, err
+	}
+
+	systemIDs := make([]string, len(systems))
+	for i, system := range systems {
+		systemIDs[i] = system.ID
+	}
+
+	feeds, err := RedisClient.WithContext(ctx).GetFeedsBatch(systemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// candidates are systems that may serve the location,
+	// geofencing zones are loaded only for them and only if they publish the feed
+	candidates := []*structs.System{}
+	zonesSystemIDs := []string{}
+	for _, system := range systems {
+		hasZones := containsFeed(feeds[system.ID], "geofencing_zones")
+
+		coverage, ok := coverages[system.ID]
+		if !ok || len(coverage.Hull) == 0 {
+			if !hasZones {
+				continue
+			}
+		} else if !geo.BBox(coverage.BBox).Expand(geofencingMargin).Contains(lon, lat) {
+			continue
+		}
+
+		candidates = append(candidates, system)
+		if hasZones {
+			zonesSystemIDs = append(zonesSystemIDs, system.ID)
+		}
+	}
+
+	zones := systemsZones(ctx, zonesSystemIDs)
+
+	distances := map[string]float64{}
+	result := []*structs.System{}
+
+	for _, system := range candidates {
+		z := zones[system.ID]
+
+		coverage, ok := coverages[system.ID]
+		if !ok || len(coverage.Hull) == 0 {
+			// systems without stations are matched by geofencing zones only
+			if z == nil || !z.contain(lon, lat) {
+				continue
+			}
+
+			b := z.bbox
+			distances[system.ID] = geo.Distance(lon, lat, (b[0]+b[2])/2, (b[1]+b[3])/2)
+			result = append(result, system)
+			continue
+		}
+
+		if !servesLocation(z, coverage, lon, lat) {
+			continue
+		}
+
+		if len(coverage.Center) == 2 {
+			distances[system.ID] = geo.Distance(lon, lat, coverage.Center[0], coverage.Center[1])
+		}
+		result = append(result, system)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return distances[result[i].ID] < distances[result[j].ID]
+	})
+
+	return result, nil
+}
+
+// servesLocation reports whether location is in system geofencing zones, if any,
+// or close to the area computed from stations
+func servesLocation(zones *cachedZones, coverage *structs.Coverage, lon, lat float64) bool {
+	if zones != nil && zones.contain(lon, lat) {
+		return true
+	}
+
+	if !geo.BBox(coverage.BBox).Expand(coverageMargin).Contains(lon, lat) {
+		return false
+	}
+
+	if len(coverage.Hull) > 3 && geo.PolygonContains([][][]float64{coverage.Hull}, lon, lat) {
+		return true
+	}
+
+	return geo.DistanceToLine(coverage.Hull, lon, lat) <= coverageMargin
+}
+
+func hasFeed(ctx context.Context, systemID, feedName string) bool {
+	feeds, err := RedisClient.WithContext(ctx).GetFeeds(systemID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get feeds", "system", systemID, "error", err)
+		return false
+	}
+
+	return containsFeed(feeds, feedName)
+}
+
+func containsFeed(feeds []structs.Feed, feedName string) bool {
+	for _, feed := range feeds {
+		if feed.Name == feedName {
+			return true
+		}
+	}
+	return false
+}
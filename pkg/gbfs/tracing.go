@@ -0,0 +1,64 @@
+package gbfs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+// traceResolvers wraps resolvers of fields returning objects with spans,
+// these resolvers load feeds; scalar fields are resolved from loaded values and not traced
+func traceResolvers(schema graphql.Schema) {
+	for _, t := range schema.TypeMap() {
+		object, ok := t.(*graphql.Object)
+		if !ok || strings.HasPrefix(object.Name(), "__") {
+			continue
+		}
+
+		for _, field := range object.Fields() {
+			if field.Resolve == nil || graphql.IsLeafType(field.Type) {
+				continue
+			}
+			field.Resolve = traceResolver(object.Name()+"."+field.Name, field.Resolve)
+		}
+	}
+}
+
+func traceResolver(name string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx, span := tracing.Start(
+			p.Context,
+			"graphql.resolve "+name,
+			attribute.String("graphql.field.path", responsePath(p.Info.Path)),
+		)
+		p.Context = ctx
+
+		result, err := resolve(p)
+
+		// batched resolvers return thunks executed after other fields
+		if thunk, ok := result.(func() (interface{}, error)); ok && err == nil {
+			return func() (interface{}, error) {
+				result, err := thunk()
+				tracing.End(span, err)
+				return result, err
+			}, nil
+		}
+
+		tracing.End(span, err)
+		return result, err
+	}
+}
+
+// responsePath returns path of the field in response, e.g. "systems.edges.0.node"
+func responsePath(path *graphql.ResponsePath) string {
+	keys := path.AsArray()
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprint(key)
+	}
+	return strings.Join(parts, ".")
+}
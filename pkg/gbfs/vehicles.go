@@ -0,0 +1,163 @@
+package gbfs
+
+import (
+	"context"
+	"sort"
+
+	"github.com/graphql-go/graphql"
+	"github.com/pkg/errors"
+
+	"github.com/chuhlomin/gbfs-go"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
+)
+
+// vehicle is a free vehicle of a specific system
+type vehicle struct {
+	gbfs.FreeBikeStatus
+	SystemID    string
+	VehicleType *gbfs.VehicleType
+}
+
+// Resolve resolves vehicle fields without custom resolvers
+// from embedded free bike status
+func (v vehicle) Resolve(p graphql.ResolveParams) (interface{}, error) {
+	p.Source = v.FreeBikeStatus
+	return resolveGBFS(p)
+}
+
+// vehiclesFilter selects vehicles by their type and remaining range,
+// empty values disable corresponding filter
+type vehiclesFilter struct {
+	FormFactor     gbfs.FormFactor
+	PropulsionType gbfs.PropulsionType
+	MinRangeMeters float64
+}
+
+func (f vehiclesFilter) match(v vehicle) bool {
+	if f.FormFactor != "" && (v.VehicleType == nil || v.VehicleType.FormFactor != f.FormFactor) {
+		return false
+	}
+	if f.PropulsionType != "" && (v.VehicleType == nil || v.VehicleType.PropulsionType != f.PropulsionType) {
+		return false
+	}
+	if f.MinRangeMeters > 0 && v.CurrentRangeMeters < f.MinRangeMeters {
+		return false
+	}
+	return true
+}
+
+func getVehicleTypes(ctx context.Context, systemID, lang string) ([]gbfs.VehicleType, error) {
+	if !hasFeed(ctx, systemID, "vehicle_types") {
+		return []gbfs.VehicleType{}, nil
+	}
+
+	url, err := feedURL(ctx, systemID, "vehicle_types", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "vehicle_types", url)
+	resp, err := Client.LoadVehicleTypes(url)
+	done(err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load vehicle types %q", url)
+	}
+
+	return resp.Data.VehicleTypes, nil
+}
+
+func getVehicles(ctx context.Context, systemID, lang string, filter vehiclesFilter) ([]vehicle, error) {
+	vehicles, _, err := loadVehicles(ctx, systemID, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []vehicle{}
+	for _, v := range vehicles {
+		if filter.match(v) {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
+
+// loadVehicles loads system vehicles with their types
+// and returns them with free_bike_status feed ttl
+func loadVehicles(ctx context.Context, systemID, lang string) ([]vehicle, int, error) {
+	url, err := feedURL(ctx, systemID, "free_bike_status", lang)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	done := tracing.StartFetch(ctx, systemID, "free_bike_status", url)
+	resp, err := Client.LoadFreeBikeStatus(url)
+	done(err)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "load free bike status %q", url)
+	}
+
+	vehicleTypes, err := getVehicleTypes(ctx, systemID, lang)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	typesByID := make(map[gbfs.ID]*gbfs.VehicleType, len(vehicleTypes))
+	for i := range vehicleTypes {
+		typesByID[vehicleTypes[i].VehicleTypeID] = &vehicleTypes[i]
+	}
+
+	result := make([]vehicle, 0, len(resp.Data.Bikes))
+	for _, bike := range resp.Data.Bikes {
+		result = append(result, vehicle{
+			FreeBikeStatus: bike,
+			SystemID:       systemID,
+			VehicleType:    typesByID[bike.VehicleTypeID],
+		})
+	}
+
+	return result, resp.TTL, nil
+}
+
+// capacityByVehicleType converts station capacity map
+// into the list sorted by vehicle type ID
+func capacityByVehicleType(capacity gbfs.Capacity) []gbfs.VehicleAvailability {
+	result := make([]gbfs.VehicleAvailability, 0, len(capacity))
+	for vehicleTypeID, count := range capacity {
+		if count < 0 {
+			continue
+		}
+		result = append(result, gbfs.VehicleAvailability{
+			VehicleTypeID: gbfs.ID(vehicleTypeID),
+			Count:         uint(count),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].VehicleTypeID < result[j].VehicleTypeID
+	})
+
+	return result
+}
+
+// resolveGBFS resolves field with default resolver,
+// converting gbfs-go types to the ones supported by GraphQL scalars
+func resolveGBFS(p graphql.ResolveParams) (interface{}, error) {
+	v, err := graphql.DefaultResolveFn(p)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := v.(type) {
+	case gbfs.Bool:
+		return bool(t), nil
+	case gbfs.Timestamp:
+		if t.Unix() <= 0 {
+			return nil, nil
+		}
+		return t.Time(), nil
+	}
+
+	return v, nil
+}
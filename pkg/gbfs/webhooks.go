@@ -0,0 +1,80 @@
+package gbfs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/ratelimit"
+	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+	"github.com/chuhlomin/gbfs-tools/pkg/webhook"
+)
+
+// WebhooksAllowPrivate allows webhooks pointing to loopback and private addresses,
+// e.g. for local development
+var WebhooksAllowPrivate bool
+
+// errWebhookAuth is returned when webhook mutation is called without API key
+var errWebhookAuth = fmt.Errorf("API key is required to manage webhooks")
+
+// addWebhook registers webhook owned by API key of the request,
+// secret is returned only here
+func addWebhook(ctx context.Context, systemID, rawURL, secret string) (*structs.Webhook, error) {
+	key := ratelimit.KeyFromContext(ctx)
+	if key == nil {
+		return nil, errWebhookAuth
+	}
+
+	u, err := webhook.CheckURL(ctx, rawURL, WebhooksAllowPrivate)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid webhook URL: %v", err)
+	}
+
+	if !hasFeed(ctx, systemID, "system_alerts") {
+		return nil, fmt.Errorf("System %q has no system_alerts feed", systemID)
+	}
+
+	if secret == "" {
+		secret = webhook.RandomString(32)
+	}
+
+	wh := structs.Webhook{
+		SystemID: systemID,
+		URL:      u.String(),
+		Secret:   secret,
+		Owner:    key.ID(),
+	}
+
+	added, err := RedisClient.WithContext(ctx).AddWebhook(wh)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, fmt.Errorf("Webhook %q is already registered for %q, remove it first", wh.URL, systemID)
+	}
+
+	return &wh, nil
+}
+
+// removeWebhook removes webhook registered with API key of the request,
+// admin keys may remove any webhook
+func removeWebhook(ctx context.Context, systemID, url string) (bool, error) {
+	key := ratelimit.KeyFromContext(ctx)
+	if key == nil {
+		return false, errWebhookAuth
+	}
+
+	redisClient := RedisClient.WithContext(ctx)
+
+	wh, err := redisClient.GetWebhook(systemID, url)
+	if err != nil {
+		return false, err
+	}
+	if wh == nil {
+		return false, nil
+	}
+	if !key.Admin && wh.Owner != key.ID() {
+		return false, fmt.Errorf("Webhook %q was registered with another API key", url)
+	}
+
+	return redisClient.RemoveWebhook(systemID, url)
+}
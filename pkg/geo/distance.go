@@ -0,0 +1,62 @@
+package geo
+
+import "math"
+
+// EarthRadius is the mean Earth radius in meters
+const EarthRadius = 6371008.8
+
+// Distance returns great-circle distance between two points in meters
+func Distance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1Rad := lat1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// DistanceToLine returns approximate distance in meters
+// from the point to the nearest segment of the line.
+// Uses equirectangular projection, good enough for distances within a city.
+func DistanceToLine(line [][]float64, lon, lat float64) float64 {
+	if len(line) == 1 {
+		return Distance(lon, lat, line[0][0], line[0][1])
+	}
+
+	kx := math.Cos(lat*math.Pi/180) * EarthRadius * math.Pi / 180
+	ky := EarthRadius * math.Pi / 180
+
+	min := math.Inf(1)
+	for i := 0; i < len(line)-1; i++ {
+		ax, ay := (line[i][0]-lon)*kx, (line[i][1]-lat)*ky
+		bx, by := (line[i+1][0]-lon)*kx, (line[i+1][1]-lat)*ky
+
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if l := dx*dx + dy*dy; l > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+		}
+
+		min = math.Min(min, math.Hypot(ax+t*dx, ay+t*dy))
+	}
+
+	return min
+}
+
+// Expand returns the box extended by given distance in meters on every side
+func (b BBox) Expand(meters float64) BBox {
+	dLat := meters / EarthRadius * 180 / math.Pi
+
+	maxAbsLat := math.Min(math.Max(math.Abs(b[1]), math.Abs(b[3])), 89)
+	dLon := dLat / math.Cos(maxAbsLat*math.Pi/180)
+
+	return BBox{
+		math.Max(b[0]-dLon, -180),
+		math.Max(b[1]-dLat, -90),
+		math.Min(b[2]+dLon, 180),
+		math.Min(b[3]+dLat, 90),
+	}
+}
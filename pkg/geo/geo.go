@@ -0,0 +1,65 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TileSize is the size of a web map tile in pixels
+const TileSize = 256
+
+// BBox represents bounding box in GeoJSON order:
+// min longitude, min latitude, max longitude, max latitude
+type BBox [4]float64
+
+// ParseBBox parses "minLon,minLat,maxLon,maxLat" string
+func ParseBBox(s string) (BBox, error) {
+	var b BBox
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return b, fmt.Errorf("expected 4 comma-separated values, got %d", len(parts))
+	}
+
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return b, fmt.Errorf("parse %q: %v", part, err)
+		}
+		b[i] = v
+	}
+
+	if b[1] > b[3] {
+		return b, fmt.Errorf("min latitude %v is greater than max latitude %v", b[1], b[3])
+	}
+
+	return b, nil
+}
+
+// Contains reports whether point is inside the box.
+// Boxes crossing the antimeridian (min longitude > max longitude) are supported.
+func (b BBox) Contains(lon, lat float64) bool {
+	if lat < b[1] || lat > b[3] {
+		return false
+	}
+
+	if b[0] <= b[2] {
+		return lon >= b[0] && lon <= b[2]
+	}
+
+	return lon >= b[0] || lon <= b[2]
+}
+
+// Project converts coordinates to Web Mercator pixel coordinates at given zoom level
+func Project(lon, lat float64, zoom int) (x, y float64) {
+	scale := TileSize * math.Exp2(float64(zoom))
+
+	lat = math.Max(math.Min(lat, 85.05112878), -85.05112878)
+	sin := math.Sin(lat * math.Pi / 180)
+
+	x = (lon + 180) / 360 * scale
+	y = (0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)) * scale
+	return
+}
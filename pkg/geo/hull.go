@@ -0,0 +1,122 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// ConvexHull returns convex hull of [lon, lat] points
+// as a closed ring in counter-clockwise order (Andrew's monotone chain).
+// For less than three distinct points the distinct points are returned as is.
+func ConvexHull(points [][]float64) [][]float64 {
+	pts := make([][]float64, 0, len(points))
+	for _, p := range points {
+		if len(p) >= 2 {
+			pts = append(pts, []float64{p[0], p[1]})
+		}
+	}
+
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i][0] == pts[j][0] {
+			return pts[i][1] < pts[j][1]
+		}
+		return pts[i][0] < pts[j][0]
+	})
+
+	// remove duplicates
+	unique := pts[:0]
+	for _, p := range pts {
+		if n := len(unique); n > 0 && p[0] == unique[n-1][0] && p[1] == unique[n-1][1] {
+			continue
+		}
+		unique = append(unique, p)
+	}
+	pts = unique
+
+	if len(pts) < 3 {
+		return pts
+	}
+
+	hull := make([][]float64, 0, 2*len(pts))
+
+	// lower hull
+	for _, p := range pts {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	// upper hull
+	lower := len(hull) + 1
+	for i := len(pts) - 2; i >= 0; i-- {
+		p := pts[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	if len(hull) < 4 { // all points are collinear
+		return [][]float64{pts[0], pts[len(pts)-1]}
+	}
+
+	return hull
+}
+
+func cross(o, a, b []float64) float64 {
+	return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+}
+
+// Bounds returns bounding box of [lon, lat] points
+func Bounds(points [][]float64) BBox {
+	b := BBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range points {
+		b[0] = math.Min(b[0], p[0])
+		b[1] = math.Min(b[1], p[1])
+		b[2] = math.Max(b[2], p[0])
+		b[3] = math.Max(b[3], p[1])
+	}
+	return b
+}
+
+// Centroid returns centroid of the closed ring.
+// For rings of less than 4 points (point or segment returned by ConvexHull)
+// and rings without area the average of the points is returned.
+func Centroid(ring [][]float64) []float64 {
+	if len(ring) == 0 {
+		return nil
+	}
+
+	if len(ring) >= 4 {
+		var area, x, y float64
+		for i := 0; i < len(ring)-1; i++ {
+			a := ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+			area += a
+			x += (ring[i][0] + ring[i+1][0]) * a
+			y += (ring[i][1] + ring[i+1][1]) * a
+		}
+
+		if area != 0 {
+			return []float64{x / (3 * area), y / (3 * area)}
+		}
+	}
+
+	return average(ring)
+}
+
+// average returns average of the points, closing point of the ring is counted once
+func average(ring [][]float64) []float64 {
+	points := ring
+	if last := len(ring) - 1; last > 0 && ring[0][0] == ring[last][0] && ring[0][1] == ring[last][1] {
+		points = ring[:last]
+	}
+
+	var x, y float64
+	for _, p := range points {
+		x += p[0]
+		y += p[1]
+	}
+	n := float64(len(points))
+	return []float64{x / n, y / n}
+}
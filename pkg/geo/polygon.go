@@ -0,0 +1,45 @@
+package geo
+
+// RingContains reports whether point is inside the linear ring
+// using the ray casting algorithm. Ring is a list of [lon, lat] positions.
+func RingContains(ring [][]float64, lon, lat float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		if len(ring[i]) < 2 || len(ring[j]) < 2 {
+			continue
+		}
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PolygonContains reports whether point is inside the polygon.
+// The first ring is the exterior ring, others are holes.
+func PolygonContains(polygon [][][]float64, lon, lat float64) bool {
+	if len(polygon) == 0 || !RingContains(polygon[0], lon, lat) {
+		return false
+	}
+
+	for _, hole := range polygon[1:] {
+		if RingContains(hole, lon, lat) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MultiPolygonContains reports whether point is inside any of the polygons
+func MultiPolygonContains(multiPolygon [][][][]float64, lon, lat float64) bool {
+	for _, polygon := range multiPolygon {
+		if PolygonContains(polygon, lon, lat) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gbfs"
+
+// operationNames are GraphQL operation names used as label values,
+// they are set on start with SetOperationNames
+var operationNames = map[string]bool{}
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status code",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by route",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	graphQLOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_operations_total",
+		Help:      "Number of GraphQL operations by name, type and status (ok or error)",
+	}, []string{"operation", "type", "status"})
+
+	graphQLOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_duration_seconds",
+		Help:      "Duration of GraphQL operations by name and type",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "type"})
+
+	upstreamFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_fetch_duration_seconds",
+		Help:      "Duration of system feeds fetches by system and feed",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"system", "feed"})
+
+	upstreamFetchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_fetch_errors_total",
+		Help:      "Number of failed system feeds fetches by system and feed",
+	}, []string{"system", "feed"})
+
+	redisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Duration of Redis commands",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command"})
+
+	redisCommandErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_command_errors_total",
+		Help:      "Number of failed Redis commands",
+	}, []string{"command"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of in-memory cache lookups by cache and result (hit or miss)",
+	}, []string{"cache", "result"})
+
+	rateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ratelimit_rejections_total",
+		Help:      "Number of requests rejected by API keys and rate limits by reason",
+	}, []string{"reason"})
+
+	writerCrawlDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "writer_crawl_duration_seconds",
+		Help:      "Duration of the last crawl of all systems",
+	})
+
+	writerCrawlTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "writer_crawl_timestamp_seconds",
+		Help:      "Time when the last crawl of all systems finished",
+	})
+
+	writerCrawlFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "writer_crawl_failures_total",
+		Help:      "Number of systems failed to crawl by stage",
+	}, []string{"stage"})
+)
+
+// Handler serves metrics in Prometheus format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// InstrumentHandler counts requests to the route and measures their duration
+func InstrumentHandler(route string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetOperationNames sets GraphQL operation names recorded by ObserveGraphQL,
+// e.g. names of persisted queries, it's not safe to call while serving requests
+func SetOperationNames(names []string) {
+	operationNames = make(map[string]bool, len(names))
+	for _, name := range names {
+		operationNames[name] = true
+	}
+}
+
+// ObserveGraphQL records GraphQL operation,
+// operations with names not set with SetOperationNames are recorded as "other",
+// so clients can't create unlimited number of label values
+func ObserveGraphQL(operation, operationType string, duration time.Duration, failed bool) {
+	switch {
+	case operation == "":
+		operation = "anonymous"
+	case !operationNames[operation]:
+		operation = "other"
+	}
+
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+
+	graphQLOperations.WithLabelValues(operation, operationType, status).Inc()
+	graphQLOperationDuration.WithLabelValues(operation, operationType).Observe(duration.Seconds())
+}
+
+// ObserveUpstream records fetch of the system feed
+func ObserveUpstream(systemID, feed string, duration time.Duration, err error) {
+	upstreamFetchDuration.WithLabelValues(systemID, feed).Observe(duration.Seconds())
+	if err != nil {
+		upstreamFetchErrors.WithLabelValues(systemID, feed).Inc()
+	}
+}
+
+// ObserveRedis records Redis command
+func ObserveRedis(command string, duration time.Duration, err error) {
+	redisCommandDuration.WithLabelValues(command).Observe(duration.Seconds())
+	if err != nil {
+		redisCommandErrors.WithLabelValues(command).Inc()
+	}
+}
+
+// CacheLookup records lookup in the in-memory cache
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// RateLimited records request rejected for the reason, e.g. "rate", "quota" or "unknown_key"
+func RateLimited(reason string) {
+	rateLimitRejections.WithLabelValues(reason).Inc()
+}
+
+// ObserveCrawl records duration of crawl of all systems finished now
+func ObserveCrawl(duration time.Duration) {
+	writerCrawlDuration.Set(duration.Seconds())
+	writerCrawlTimestamp.SetToCurrentTime()
+}
+
+// CrawlFailure records system failed to crawl at the stage, e.g. "gbfs" or "coverage"
+func CrawlFailure(stage string) {
+	writerCrawlFailures.WithLabelValues(stage).Inc()
+}
+
+// statusRecorder remembers response status code,
+// it supports streaming and WebSocket upgrades of the wrapped writer
+// and exposes it to http.ResponseController
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,267 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
+	"github.com/chuhlomin/gbfs-tools/pkg/redis"
+	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+)
+
+// APIKeyHeader is HTTP header with API key, it can also be sent as APIKeyParam query parameter
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyParam is query parameter with API key
+const APIKeyParam = "apiKey"
+
+// keysCacheTTL is how long API keys are kept in memory
+const keysCacheTTL = time.Minute
+
+// Config configures limits of clients
+type Config struct {
+	// Required rejects requests without API key
+	Required bool
+
+	// Anonymous limits requests without API key by client IP address
+	Anonymous structs.APIKey
+
+	// ProxyHops is number of trusted proxies in front of server appending to X-Forwarded-For header,
+	// client IP address is taken from the entry added by the farthest of them; 0 uses remote address
+	ProxyHops int
+}
+
+// Limiter authenticates clients by API keys and limits their requests
+type Limiter struct {
+	redis  *redis.Client
+	config Config
+
+	mu   sync.Mutex
+	keys map[string]cachedKey
+}
+
+type cachedKey struct {
+	key     *structs.APIKey
+	expires time.Time
+}
+
+type apiKeyKey struct{}
+
+// KeyFromContext returns API key of the request, nil for requests without key
+func KeyFromContext(ctx context.Context) *structs.APIKey {
+	key, _ := ctx.Value(apiKeyKey{}).(*structs.APIKey)
+	return key
+}
+
+// NewLimiter returns limiter with API keys and counters stored in Redis
+func NewLimiter(redisClient *redis.Client, config Config) *Limiter {
+	return &Limiter{
+		redis:  redisClient,
+		config: config,
+		keys:   map[string]cachedKey{},
+	}
+}
+
+// Handler checks API key of the request and takes the request from client limits,
+// rejected requests get 401, 403 or 429 responses.
+// Requests are passed through when Redis fails.
+func (l *Limiter) Handler(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		redisClient := l.redis.WithContext(r.Context())
+
+		ctx, client, limits, rej := l.client(r, redisClient)
+		if rej != nil {
+			metrics.RateLimited(rej.reason)
+			http.Error(w, rej.message, rej.status)
+			return
+		}
+		r = r.WithContext(ctx)
+
+		// usage of API keys is counted even without limits
+		if client == "" || KeyFromContext(ctx) == nil && limits.Rate == 0 && limits.Quota == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		burst := limits.Burst
+		if burst < int(math.Ceil(limits.Rate)) {
+			burst = int(math.Ceil(limits.Rate))
+		}
+
+		a, err := redisClient.Take(client, limits.Rate, burst, limits.Quota)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to check rate limit", "client", limits.Name, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if limits.Rate > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(burst))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(a.Remaining))
+		}
+		if limits.Quota > 0 {
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(limits.Quota, 10))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(limits.Quota-a.Used, 0), 10))
+		}
+
+		if !a.Allowed {
+			reason := "rate"
+			if limits.Quota > 0 && a.Used >= limits.Quota {
+				reason = "quota"
+			}
+			metrics.RateLimited(reason)
+			slog.InfoContext(ctx, "Rate limited", "client", limits.Name, "reason", reason, "retry_after", a.RetryAfter)
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(a.RetryAfter.Seconds()))))
+			http.Error(w, "Too many requests, "+reason+" limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// HandlerUsage returns limits and today usage of API key of the request,
+// it has to be wrapped with Handler
+func (l *Limiter) HandlerUsage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := KeyFromContext(r.Context())
+		if key == nil {
+			http.Error(w, "Missing API key", http.StatusUnauthorized)
+			return
+		}
+
+		usage, err := l.redis.WithContext(r.Context()).GetUsage("key:"+key.Key, time.Now())
+		if err != nil {
+			http.Error(w, "Failed to get usage", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Name    string  `json:"name"`
+			Rate    float64 `json:"rate"`
+			Burst   int     `json:"burst"`
+			Quota   int64   `json:"quota"`
+			Allowed int64   `json:"allowed"`
+			Limited int64   `json:"limited"`
+		}{
+			Name:    key.Name,
+			Rate:    key.Rate,
+			Burst:   key.Burst,
+			Quota:   key.Quota,
+			Allowed: usage["allowed"],
+			Limited: usage["limited"],
+		})
+	})
+}
+
+// rejection is reason of request rejection
+type rejection struct {
+	status  int
+	reason  string
+	message string
+}
+
+var (
+	errMissingKey  = &rejection{http.StatusUnauthorized, "missing_key", "Missing API key"}
+	errUnknownKey  = &rejection{http.StatusUnauthorized, "unknown_key", "Unknown API key"}
+	errDisabledKey = &rejection{http.StatusForbidden, "disabled_key", "API key is disabled"}
+)
+
+// client returns context with API key of the request, client ID and its limits,
+// empty client ID is returned when API key could not be loaded
+func (l *Limiter) client(r *http.Request, redisClient *redis.Client) (context.Context, string, structs.APIKey, *rejection) {
+	ctx := r.Context()
+
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get(APIKeyParam)
+	}
+
+	if key == "" {
+		if l.config.Required {
+			return ctx, "", structs.APIKey{}, errMissingKey
+		}
+		limits := l.config.Anonymous
+		limits.Name = "ip:" + l.clientIP(r)
+		return ctx, limits.Name, limits, nil
+	}
+
+	apiKey, err := l.apiKey(redisClient, key)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get API key", "error", err)
+		return ctx, "", structs.APIKey{}, nil
+	}
+	if apiKey == nil {
+		return ctx, "", structs.APIKey{}, errUnknownKey
+	}
+	if apiKey.Disabled {
+		return ctx, "", structs.APIKey{}, errDisabledKey
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("gbfs.client", apiKey.Name))
+	return context.WithValue(ctx, apiKeyKey{}, apiKey), "key:" + apiKey.Key, *apiKey, nil
+}
+
+// apiKey returns API key from memory or Redis, nil for unknown keys
+func (l *Limiter) apiKey(redisClient *redis.Client, key string) (*structs.APIKey, error) {
+	l.mu.Lock()
+	cached, ok := l.keys[key]
+	l.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	apiKey, err := redisClient.GetAPIKey(key)
+	if err != nil || apiKey == nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, c := range l.keys {
+		if time.Now().After(c.expires) {
+			delete(l.keys, k)
+		}
+	}
+	l.keys[key] = cachedKey{key: apiKey, expires: time.Now().Add(keysCacheTTL)}
+
+	return apiKey, nil
+}
+
+// clientIP returns IP address of the client
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.config.ProxyHops > 0 {
+		// entries on the left are sent by client and can't be trusted
+		var forwarded []string
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			forwarded = append(forwarded, strings.Split(value, ",")...)
+		}
+		if len(forwarded) >= l.config.ProxyHops {
+			return strings.TrimSpace(forwarded[len(forwarded)-l.config.ProxyHops])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
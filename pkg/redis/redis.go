@@ -2,14 +2,23 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mediocregopher/radix/v4"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/chuhlomin/gbfs-go"
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
 	"github.com/chuhlomin/gbfs-tools/pkg/structs"
+	"github.com/chuhlomin/gbfs-tools/pkg/tracing"
 )
 
 // Client represents layer between server, writer and Redis
@@ -31,7 +40,7 @@ func NewClient(ctx context.Context, network, addr, auth string) (*Client, error)
 	}
 
 	var pong string
-	err = client.Do(ctx, radix.Cmd(&pong, "PING"))
+	err = client.Do(ctx, cmd(&pong, "PING"))
 	if err != nil {
 		return nil, err
 	}
@@ -41,53 +50,181 @@ func NewClient(ctx context.Context, network, addr, auth string) (*Client, error)
 
 	return &Client{
 		ctx:    ctx,
-		client: client,
+		client: instrumentedClient{client},
 	}, nil
 }
 
-func (c *Client) WriteSystem(system gbfs.System) error {
-	return c.client.Do(c.ctx, radix.Cmd(nil, "SET", "system:"+system.ID, packSystem(system)))
+// Ping checks that Redis is reachable
+func (c *Client) Ping() error {
+	var pong string
+	if err := c.client.Do(c.ctx, cmd(&pong, "PING")); err != nil {
+		return err
+	}
+	if pong != "PONG" {
+		return fmt.Errorf("PING failed, got %q", pong)
+	}
+	return nil
+}
+
+// WithContext returns client sending commands with ctx,
+// so they are traced and logged as part of the request
+func (c *Client) WithContext(ctx context.Context) *Client {
+	return &Client{
+		ctx:    ctx,
+		client: c.client,
+	}
 }
 
-var systems []*structs.System
+// instrumentedClient records duration and errors of Redis commands,
+// traces and logs them
+type instrumentedClient struct {
+	radix.Client
+}
 
-func (c *Client) CacheAllSystems() error {
-	var keys []string
-	if err := c.client.Do(c.ctx, radix.Cmd(&keys, "KEYS", "system:*")); err != nil {
-		return errors.Wrap(err, "get systems keys")
+func (c instrumentedClient) Do(ctx context.Context, action radix.Action) error {
+	command := "PIPELINE"
+	if a, ok := action.(namedAction); ok {
+		command = a.command
 	}
 
-	var vals []string
-	if err := c.client.Do(c.ctx, radix.Cmd(&vals, "MGET", keys...)); err != nil {
-		return errors.Wrap(err, "get systems keys")
+	ctx, span := tracing.Start(
+		ctx,
+		"redis "+command,
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", command),
+	)
+
+	start := time.Now()
+	err := c.Client.Do(ctx, action)
+	duration := time.Since(start)
+
+	metrics.ObserveRedis(command, duration, err)
+	tracing.End(span, err)
+
+	if err != nil {
+		slog.WarnContext(ctx, "Redis command failed", "command", command, "duration", duration, "error", err)
+	} else {
+		slog.DebugContext(ctx, "Redis command", "command", command, "duration", duration)
 	}
 
-	systems = []*structs.System{}
-	for _, val := range vals {
-		systems = append(systems, unpackSystem(val))
+	return err
+}
+
+// namedAction is Redis command with its name known for metrics
+type namedAction struct {
+	radix.Action
+	command string
+}
+
+func cmd(rcv interface{}, command string, args ...string) radix.Action {
+	return namedAction{Action: radix.Cmd(rcv, command, args...), command: command}
+}
+
+func flatCmd(rcv interface{}, command string, args ...interface{}) radix.Action {
+	return namedAction{Action: radix.FlatCmd(rcv, command, args...), command: command}
+}
+
+// WriteSystem writes system listed in systems.csv, such systems are enabled
+func (c *Client) WriteSystem(system gbfs.System) error {
+	return c.client.Do(c.ctx, cmd(nil, "SET", "system:"+system.ID, packSystem(&structs.System{
+		ID:               system.ID,
+		CountryCode:      system.CountryCode,
+		Name:             system.Name,
+		Location:         system.Location,
+		URL:              system.URL,
+		AutoDiscoveryURL: system.AutoDiscoveryURL,
+		IsEnabled:        true,
+	})))
+}
+
+// DisableSystemsExcept disables systems not listed in systemIDs,
+// e.g. systems removed from systems.csv
+func (c *Client) DisableSystemsExcept(systemIDs []string) error {
+	all, err := c.GetSystems()
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(systemIDs))
+	for _, id := range systemIDs {
+		listed[id] = true
+	}
+
+	for _, system := range all {
+		if listed[system.ID] || !system.IsEnabled {
+			continue
+		}
+
+		system.IsEnabled = false
+		if err := c.client.Do(c.ctx, cmd(nil, "SET", "system:"+system.ID, packSystem(system))); err != nil {
+			return errors.Wrapf(err, "disable system %q", system.ID)
+		}
 	}
 
 	return nil
 }
 
+var (
+	systems   []*structs.System
+	systemsMu sync.RWMutex
+)
+
+func (c *Client) CacheAllSystems() error {
+	_, err := c.GetSystems()
+	return err
+}
+
 func (c *Client) GetSystems() ([]*structs.System, error) {
-	if err := c.CacheAllSystems(); err != nil {
-		return nil, err
+	var keys []string
+	if err := c.client.Do(c.ctx, cmd(&keys, "KEYS", "system:*")); err != nil {
+		return nil, errors.Wrap(err, "get systems keys")
+	}
+
+	result := []*structs.System{}
+	if len(keys) > 0 {
+		var vals []string
+		if err := c.client.Do(c.ctx, cmd(&vals, "MGET", keys...)); err != nil {
+			return nil, errors.Wrap(err, "get systems keys")
+		}
+
+		for _, val := range vals {
+			result = append(result, unpackSystem(val))
+		}
 	}
 
-	return systems, nil
+	systemsMu.Lock()
+	systems = result
+	systemsMu.Unlock()
+
+	return result, nil
+}
+
+// CachedSystems returns number of systems loaded in memory
+func (c *Client) CachedSystems() int {
+	systemsMu.RLock()
+	defer systemsMu.RUnlock()
+	return len(systems)
 }
 
 func (c *Client) GetSystem(systemID string) (*structs.System, error) {
 	var v string
-	if err := c.client.Do(c.ctx, radix.Cmd(&v, "GET", "system:"+systemID)); err != nil {
+	if err := c.client.Do(c.ctx, cmd(&v, "GET", "system:"+systemID)); err != nil {
 		return nil, errors.Wrapf(err, "get system %q", systemID)
 	}
 
+	if v == "" {
+		return nil, nil
+	}
+
 	return unpackSystem(v), nil
 }
 
-func packSystem(system gbfs.System) string {
+func packSystem(system *structs.System) string {
+	enabled := "0"
+	if system.IsEnabled {
+		enabled = "1"
+	}
+
 	return strings.Join(
 		[]string{
 			system.ID,
@@ -96,6 +233,7 @@ func packSystem(system gbfs.System) string {
 			system.URL,
 			system.CountryCode,
 			system.Location,
+			enabled,
 		},
 		"\n",
 	)
@@ -110,6 +248,8 @@ func unpackSystem(val string) *structs.System {
 		URL:              vv[3],
 		CountryCode:      vv[4],
 		Location:         vv[5],
+		// systems written before enabled flag was added are enabled
+		IsEnabled: len(vv) < 7 || vv[6] == "1",
 	}
 }
 
@@ -117,7 +257,7 @@ func (c *Client) WriteFeeds(systemID, language string, feeds []gbfs.Feed) error
 	for _, feed := range feeds {
 		err := c.client.Do(
 			c.ctx,
-			radix.Cmd(
+			cmd(
 				nil,
 				"SET",
 				fmt.Sprintf("feed:%s:%s:%s", systemID, feed.Name, language),
@@ -131,33 +271,49 @@ func (c *Client) WriteFeeds(systemID, language string, feeds []gbfs.Feed) error
 	return nil
 }
 
+// defaultLanguage is used when feed is not available in requested language
+const defaultLanguage = "en"
+
+// GetFeedURL returns URL of the system feed in given language,
+// falling back to defaultLanguage and then to the first language alphabetically
 func (c *Client) GetFeedURL(systemID, feedName, language string) (string, error) {
 	var url string
-	err := c.client.Do(c.ctx, radix.Cmd(&url, "GET", fmt.Sprintf("feed:%s:%s:%s", systemID, feedName, language)))
+	err := c.client.Do(c.ctx, cmd(&url, "GET", fmt.Sprintf("feed:%s:%s:%s", systemID, feedName, language)))
 	if err != nil {
 		return "", errors.Wrap(err, "GET")
 	}
 
-	if url == "" { // fallback to any other language
+	if url == "" && language != defaultLanguage {
+		err = c.client.Do(c.ctx, cmd(&url, "GET", fmt.Sprintf("feed:%s:%s:%s", systemID, feedName, defaultLanguage)))
+		if err != nil {
+			return "", errors.Wrap(err, "GET")
+		}
+	}
+
+	if url == "" { // fallback to the first language alphabetically
 		var keys []string
-		err = c.client.Do(c.ctx, radix.Cmd(&keys, "KEYS", fmt.Sprintf("feed:%s:%s:*", systemID, feedName)))
+		err = c.client.Do(c.ctx, cmd(&keys, "KEYS", fmt.Sprintf("feed:%s:%s:*", systemID, feedName)))
 		if err != nil {
 			return "", errors.Wrap(err, "KEYS")
 		}
 
 		if len(keys) > 0 {
-			err = c.client.Do(c.ctx, radix.Cmd(&url, "GET", keys[0]))
+			sort.Strings(keys)
+			err = c.client.Do(c.ctx, cmd(&url, "GET", keys[0]))
 		}
 	}
 
 	return url, err
 }
 
-var allFeeds map[string][]structs.Feed
+var (
+	allFeeds   map[string][]structs.Feed
+	allFeedsMu sync.RWMutex
+)
 
 func (c *Client) CacheAllFeeds() error {
 	var keys []string
-	if err := c.client.Do(c.ctx, radix.Cmd(&keys, "KEYS", "feed:*")); err != nil {
+	if err := c.client.Do(c.ctx, cmd(&keys, "KEYS", "feed:*")); err != nil {
 		return errors.Wrapf(err, "keys for all feeds")
 	}
 
@@ -165,39 +321,47 @@ func (c *Client) CacheAllFeeds() error {
 		return nil
 	}
 
-	allFeeds = map[string][]structs.Feed{}
+	feeds := map[string][]structs.Feed{}
 
 	var urls []string
-	if err := c.client.Do(c.ctx, radix.Cmd(&urls, "MGET", keys...)); err != nil {
+	if err := c.client.Do(c.ctx, cmd(&urls, "MGET", keys...)); err != nil {
 		return errors.Wrap(err, "mget for all feeds")
 	}
 
 	for i, key := range keys {
 		systemID, feedName, language := splitFeedKey(key)
 
-		feed := structs.Feed{
+		feeds[systemID] = append(feeds[systemID], structs.Feed{
 			Name:     feedName,
 			URL:      urls[i],
 			Language: language,
-		}
-
-		if _, ok := allFeeds[systemID]; !ok {
-			allFeeds[systemID] = []structs.Feed{feed}
-		} else {
-			allFeeds[systemID] = append(allFeeds[systemID], feed)
-		}
+		})
 	}
 
+	allFeedsMu.Lock()
+	allFeeds = feeds
+	allFeedsMu.Unlock()
+
 	return nil
 }
 
+// cachedFeeds returns feeds of the system loaded in memory
+func cachedFeeds(systemID string) ([]structs.Feed, bool) {
+	allFeedsMu.RLock()
+	defer allFeedsMu.RUnlock()
+	feeds, ok := allFeeds[systemID]
+	return feeds, ok
+}
+
 func (c *Client) GetFeeds(systemID string) ([]structs.Feed, error) {
-	if feeds, ok := allFeeds[systemID]; ok {
+	feeds, ok := cachedFeeds(systemID)
+	metrics.CacheLookup("feeds", ok)
+	if ok {
 		return feeds, nil
 	}
 
 	var keys []string
-	if err := c.client.Do(c.ctx, radix.Cmd(&keys, "KEYS", fmt.Sprintf("feed:%s:*", systemID))); err != nil {
+	if err := c.client.Do(c.ctx, cmd(&keys, "KEYS", fmt.Sprintf("feed:%s:*", systemID))); err != nil {
 		return nil, errors.Wrapf(err, "keys for %q feeds", systemID)
 	}
 
@@ -208,7 +372,7 @@ func (c *Client) GetFeeds(systemID string) ([]structs.Feed, error) {
 	result := []structs.Feed{}
 
 	var urls []string
-	if err := c.client.Do(c.ctx, radix.Cmd(&urls, "MGET", keys...)); err != nil {
+	if err := c.client.Do(c.ctx, cmd(&urls, "MGET", keys...)); err != nil {
 		return nil, errors.Wrapf(err, "mget for %q feeds", systemID)
 	}
 
@@ -228,21 +392,60 @@ func (c *Client) GetFeeds(systemID string) ([]structs.Feed, error) {
 	return result, nil
 }
 
-func (c *Client) GetFeedsLanguages(systemID string) ([]string, error) {
+// GetFeedsBatch returns feeds of multiple systems in two round trips:
+// pipeline of KEYS commands and a single MGET for all keys
+func (c *Client) GetFeedsBatch(systemIDs []string) (map[string][]structs.Feed, error) {
+	result := make(map[string][]structs.Feed, len(systemIDs))
+
+	var missing []string
+	for _, systemID := range systemIDs {
+		feeds, ok := cachedFeeds(systemID)
+		metrics.CacheLookup("feeds", ok)
+		if ok {
+			result[systemID] = feeds
+			continue
+		}
+		missing = append(missing, systemID)
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	keysBySystem := make([][]string, len(missing))
+	p := radix.NewPipeline()
+	for i, systemID := range missing {
+		p.Append(radix.Cmd(&keysBySystem[i], "KEYS", fmt.Sprintf("feed:%s:*", systemID)))
+	}
+	if err := c.client.Do(c.ctx, p); err != nil {
+		return nil, errors.Wrap(err, "keys for feeds")
+	}
+
 	var keys []string
-	if err := c.client.Do(c.ctx, radix.Cmd(&keys, "KEYS", fmt.Sprintf("feed:%s:*", systemID))); err != nil {
-		return nil, errors.Wrapf(err, "keys for %q feeds", systemID)
+	for _, k := range keysBySystem {
+		keys = append(keys, k...)
 	}
 
-	langs := map[string]struct{}{}
-	for _, key := range keys {
-		_, _, language := splitFeedKey(key)
-		langs[language] = struct{}{}
+	if len(keys) == 0 {
+		return result, nil
 	}
 
-	var result []string
-	for lang := range langs {
-		result = append(result, lang)
+	var urls []string
+	if err := c.client.Do(c.ctx, cmd(&urls, "MGET", keys...)); err != nil {
+		return nil, errors.Wrap(err, "mget for feeds")
+	}
+
+	for i, key := range keys {
+		systemID, feedName, language := splitFeedKey(key)
+
+		result[systemID] = append(
+			result[systemID],
+			structs.Feed{
+				Name:     feedName,
+				URL:      urls[i],
+				Language: language,
+			},
+		)
 	}
 
 	return result, nil
@@ -253,3 +456,513 @@ func splitFeedKey(key string) (systemID, feedName, language string) {
 	systemID, feedName, language = v[1], v[2], v[3]
 	return
 }
+
+func (c *Client) WriteCoverage(systemID string, coverage structs.Coverage) error {
+	b, err := json.Marshal(coverage)
+	if err != nil {
+		return errors.Wrapf(err, "marshal coverage %q", systemID)
+	}
+
+	return c.client.Do(c.ctx, cmd(nil, "SET", "coverage:"+systemID, string(b)))
+}
+
+// coveragesTTL is how long cached coverages are used,
+// they are updated by writer on every run
+const coveragesTTL = time.Minute
+
+var (
+	allCoverages       map[string]*structs.Coverage
+	allCoveragesLoaded time.Time
+	allCoveragesMu     sync.RWMutex
+	allCoveragesReload sync.Mutex // only one request reloads expired coverages
+)
+
+func (c *Client) CacheAllCoverages() error {
+	var keys []string
+	if err := c.client.Do(c.ctx, cmd(&keys, "KEYS", "coverage:*")); err != nil {
+		return errors.Wrap(err, "keys for all coverages")
+	}
+
+	coverages := map[string]*structs.Coverage{}
+
+	if len(keys) > 0 {
+		var vals []string
+		if err := c.client.Do(c.ctx, cmd(&vals, "MGET", keys...)); err != nil {
+			return errors.Wrap(err, "mget for all coverages")
+		}
+
+		for i, key := range keys {
+			coverage, err := unpackCoverage(vals[i])
+			if err != nil {
+				return errors.Wrapf(err, "unpack %q", key)
+			}
+			coverages[strings.TrimPrefix(key, "coverage:")] = coverage
+		}
+	}
+
+	allCoveragesMu.Lock()
+	allCoverages = coverages
+	allCoveragesLoaded = time.Now()
+	allCoveragesMu.Unlock()
+	return nil
+}
+
+// GetCoverages returns coverages of all systems, mapped by system ID,
+// coverages are reloaded after coveragesTTL, stale ones are returned if reload fails
+func (c *Client) GetCoverages() (map[string]*structs.Coverage, error) {
+	allCoveragesMu.RLock()
+	coverages, loaded := allCoverages, allCoveragesLoaded
+	allCoveragesMu.RUnlock()
+
+	if coverages != nil && time.Since(loaded) < coveragesTTL {
+		return coverages, nil
+	}
+
+	allCoveragesReload.Lock()
+	defer allCoveragesReload.Unlock()
+
+	allCoveragesMu.RLock()
+	if allCoverages != nil && allCoveragesLoaded.After(loaded) {
+		// reloaded by concurrent request
+		defer allCoveragesMu.RUnlock()
+		return allCoverages, nil
+	}
+	allCoveragesMu.RUnlock()
+
+	if err := c.CacheAllCoverages(); err != nil {
+		if coverages != nil {
+			slog.WarnContext(c.ctx, "Failed to reload coverages", "error", err)
+			return coverages, nil
+		}
+		return nil, err
+	}
+
+	allCoveragesMu.RLock()
+	defer allCoveragesMu.RUnlock()
+	return allCoverages, nil
+}
+
+// GetCoverage returns system coverage or nil if it was not computed yet
+func (c *Client) GetCoverage(systemID string) (*structs.Coverage, error) {
+	coverages, err := c.GetCoverages()
+	if err != nil {
+		slog.WarnContext(c.ctx, "Failed to get coverages", "error", err)
+	}
+
+	coverage, ok := coverages[systemID]
+	metrics.CacheLookup("coverages", ok)
+	if ok {
+		return coverage, nil
+	}
+
+	var v string
+	if err := c.client.Do(c.ctx, cmd(&v, "GET", "coverage:"+systemID)); err != nil {
+		return nil, errors.Wrapf(err, "get coverage %q", systemID)
+	}
+
+	if v == "" {
+		return nil, nil
+	}
+
+	return unpackCoverage(v)
+}
+
+func unpackCoverage(val string) (*structs.Coverage, error) {
+	var coverage structs.Coverage
+	if err := json.Unmarshal([]byte(val), &coverage); err != nil {
+		return nil, err
+	}
+	return &coverage, nil
+}
+
+// WriteSystemStatus writes result of the last system check
+func (c *Client) WriteSystemStatus(systemID string, status structs.SystemStatus) error {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return errors.Wrapf(err, "marshal status %q", systemID)
+	}
+
+	return c.client.Do(c.ctx, cmd(nil, "SET", "system_status:"+systemID, string(b)))
+}
+
+// systemStatusesTTL is how long cached system statuses are used,
+// they are updated by writer on every run
+const systemStatusesTTL = time.Minute
+
+var (
+	allSystemStatuses       map[string]*structs.SystemStatus
+	allSystemStatusesLoaded time.Time
+	allSystemStatusesMu     sync.Mutex
+	allSystemStatusesReload sync.Mutex // only one request reloads expired statuses
+)
+
+func (c *Client) CacheAllSystemStatuses() error {
+	var keys []string
+	if err := c.client.Do(c.ctx, cmd(&keys, "KEYS", "system_status:*")); err != nil {
+		return errors.Wrap(err, "keys for all system statuses")
+	}
+
+	statuses := map[string]*structs.SystemStatus{}
+
+	if len(keys) > 0 {
+		var vals []string
+		if err := c.client.Do(c.ctx, cmd(&vals, "MGET", keys...)); err != nil {
+			return errors.Wrap(err, "mget for all system statuses")
+		}
+
+		for i, key := range keys {
+			var status structs.SystemStatus
+			if err := json.Unmarshal([]byte(vals[i]), &status); err != nil {
+				return errors.Wrapf(err, "unpack %q", key)
+			}
+			statuses[strings.TrimPrefix(key, "system_status:")] = &status
+		}
+	}
+
+	allSystemStatusesMu.Lock()
+	allSystemStatuses = statuses
+	allSystemStatusesLoaded = time.Now()
+	allSystemStatusesMu.Unlock()
+	return nil
+}
+
+// GetSystemStatuses returns statuses of all checked systems, mapped by system ID,
+// statuses are reloaded after systemStatusesTTL, stale ones are returned if reload fails
+func (c *Client) GetSystemStatuses() (map[string]*structs.SystemStatus, error) {
+	allSystemStatusesMu.Lock()
+	statuses, loaded := allSystemStatuses, allSystemStatusesLoaded
+	allSystemStatusesMu.Unlock()
+
+	if statuses != nil && time.Since(loaded) < systemStatusesTTL {
+		return statuses, nil
+	}
+
+	allSystemStatusesReload.Lock()
+	defer allSystemStatusesReload.Unlock()
+
+	allSystemStatusesMu.Lock()
+	if allSystemStatuses != nil && allSystemStatusesLoaded.After(loaded) {
+		// reloaded by concurrent request
+		defer allSystemStatusesMu.Unlock()
+		return allSystemStatuses, nil
+	}
+	allSystemStatusesMu.Unlock()
+
+	if err := c.CacheAllSystemStatuses(); err != nil {
+		if statuses != nil {
+			slog.WarnContext(c.ctx, "Failed to reload system statuses", "error", err)
+			return statuses, nil
+		}
+		return nil, err
+	}
+
+	allSystemStatusesMu.Lock()
+	defer allSystemStatusesMu.Unlock()
+	return allSystemStatuses, nil
+}
+
+// webhookValue is stored in "webhooks:<systemID>" hash by webhook URL
+type webhookValue struct {
+	Secret string `json:"secret"`
+	Owner  string `json:"owner,omitempty"`
+}
+
+// AddWebhook registers system alerts subscriber,
+// returns false if the URL is already registered for the system
+func (c *Client) AddWebhook(webhook structs.Webhook) (bool, error) {
+	b, err := json.Marshal(webhookValue{Secret: webhook.Secret, Owner: webhook.Owner})
+	if err != nil {
+		return false, errors.Wrap(err, "marshal webhook")
+	}
+
+	var added int
+	if err := c.client.Do(c.ctx, cmd(&added, "HSETNX", "webhooks:"+webhook.SystemID, webhook.URL, string(b))); err != nil {
+		return false, errors.Wrapf(err, "add webhook %q for %q", webhook.URL, webhook.SystemID)
+	}
+	return added > 0, nil
+}
+
+// RemoveWebhook removes subscriber, returns false if it was not registered
+func (c *Client) RemoveWebhook(systemID, url string) (bool, error) {
+	var removed int
+	if err := c.client.Do(c.ctx, cmd(&removed, "HDEL", "webhooks:"+systemID, url)); err != nil {
+		return false, errors.Wrapf(err, "remove webhook %q for %q", url, systemID)
+	}
+	return removed > 0, nil
+}
+
+// GetWebhook returns subscriber by URL, nil if it is not registered
+func (c *Client) GetWebhook(systemID, url string) (*structs.Webhook, error) {
+	var val string
+	if err := c.client.Do(c.ctx, cmd(&val, "HGET", "webhooks:"+systemID, url)); err != nil {
+		return nil, errors.Wrapf(err, "get webhook %q for %q", url, systemID)
+	}
+	if val == "" {
+		return nil, nil
+	}
+
+	wh := parseWebhook(systemID, url, val)
+	return &wh, nil
+}
+
+func (c *Client) GetWebhooks(systemID string) ([]structs.Webhook, error) {
+	var vals map[string]string
+	if err := c.client.Do(c.ctx, cmd(&vals, "HGETALL", "webhooks:"+systemID)); err != nil {
+		return nil, errors.Wrapf(err, "get webhooks for %q", systemID)
+	}
+
+	result := []structs.Webhook{}
+	for url, val := range vals {
+		result = append(result, parseWebhook(systemID, url, val))
+	}
+	return result, nil
+}
+
+// parseWebhook parses stored webhook value,
+// webhooks registered before owners were recorded have plain secret as value
+func parseWebhook(systemID, url, val string) structs.Webhook {
+	wh := structs.Webhook{SystemID: systemID, URL: url}
+
+	var v webhookValue
+	if err := json.Unmarshal([]byte(val), &v); err != nil {
+		wh.Secret = val
+		return wh
+	}
+
+	wh.Secret = v.Secret
+	wh.Owner = v.Owner
+	return wh
+}
+
+// GetWebhooksSystems returns IDs of systems having at least one subscriber
+func (c *Client) GetWebhooksSystems() ([]string, error) {
+	var keys []string
+	if err := c.client.Do(c.ctx, cmd(&keys, "KEYS", "webhooks:*")); err != nil {
+		return nil, errors.Wrap(err, "keys for webhooks")
+	}
+
+	result := []string{}
+	for _, key := range keys {
+		result = append(result, strings.TrimPrefix(key, "webhooks:"))
+	}
+	return result, nil
+}
+
+// alertsSeededKey is a set of systems which alerts were checked at least once
+const alertsSeededKey = "alerts_seeded"
+
+// GetAlertsState returns last seen system alerts, mapped by alert ID,
+// seeded is false if system alerts were never checked
+func (c *Client) GetAlertsState(systemID string) (state map[string]string, seeded bool, err error) {
+	var member int
+	p := radix.NewPipeline()
+	p.Append(radix.Cmd(&state, "HGETALL", "alerts:"+systemID))
+	p.Append(radix.Cmd(&member, "SISMEMBER", alertsSeededKey, systemID))
+	if err = c.client.Do(c.ctx, p); err != nil {
+		return nil, false, errors.Wrapf(err, "get alerts state for %q", systemID)
+	}
+
+	// state written before seeded set was introduced is seeded too
+	return state, member == 1 || len(state) > 0, nil
+}
+
+// WriteAlertsState replaces last seen system alerts and marks system as seeded
+func (c *Client) WriteAlertsState(systemID string, state map[string]string) error {
+	if err := c.client.Do(c.ctx, cmd(nil, "DEL", "alerts:"+systemID)); err != nil {
+		return errors.Wrapf(err, "delete alerts state for %q", systemID)
+	}
+
+	if len(state) > 0 {
+		if err := c.client.Do(c.ctx, flatCmd(nil, "HSET", "alerts:"+systemID, state)); err != nil {
+			return errors.Wrapf(err, "write alerts state for %q", systemID)
+		}
+	}
+
+	if err := c.client.Do(c.ctx, cmd(nil, "SADD", alertsSeededKey, systemID)); err != nil {
+		return errors.Wrapf(err, "mark alerts state seeded for %q", systemID)
+	}
+	return nil
+}
+
+// persistedQueryTTL is how long queries registered by clients are kept
+const persistedQueryTTL = 30 * 24 * 60 * 60
+
+// WritePersistedQuery stores query registered with Automatic Persisted Queries protocol
+func (c *Client) WritePersistedQuery(hash, query string) error {
+	err := c.client.Do(
+		c.ctx,
+		flatCmd(nil, "SET", "query:"+hash, query, "EX", persistedQueryTTL),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "write persisted query %q", hash)
+	}
+	return nil
+}
+
+// GetPersistedQuery returns query by its hash, empty string is returned for unknown queries
+func (c *Client) GetPersistedQuery(hash string) (string, error) {
+	var query string
+	if err := c.client.Do(c.ctx, cmd(&query, "GET", "query:"+hash)); err != nil {
+		return "", errors.Wrapf(err, "get persisted query %q", hash)
+	}
+	return query, nil
+}
+
+// writerLastRunKey stores time when writer finished writing systems and feeds
+const writerLastRunKey = "writer:last_run"
+
+// WriteLastRun stores time when writer finished
+func (c *Client) WriteLastRun(t time.Time) error {
+	return c.client.Do(c.ctx, cmd(nil, "SET", writerLastRunKey, t.UTC().Format(time.RFC3339)))
+}
+
+// GetLastRun returns time when writer finished last time, zero time if it never did
+func (c *Client) GetLastRun() (time.Time, error) {
+	var v string
+	if err := c.client.Do(c.ctx, cmd(&v, "GET", writerLastRunKey)); err != nil {
+		return time.Time{}, errors.Wrap(err, "get writer last run")
+	}
+
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "parse writer last run %q", v)
+	}
+	return t, nil
+}
+
+// GetAPIKey returns API key stored as hash "apikey:<key>" with fields
+// name, rate, burst, quota, disabled and admin; nil is returned for unknown keys
+func (c *Client) GetAPIKey(key string) (*structs.APIKey, error) {
+	var vals map[string]string
+	if err := c.client.Do(c.ctx, cmd(&vals, "HGETALL", "apikey:"+key)); err != nil {
+		return nil, errors.Wrap(err, "get API key")
+	}
+
+	if len(vals) == 0 {
+		return nil, nil
+	}
+
+	apiKey := structs.APIKey{Key: key, Name: vals["name"]}
+	var err error
+	if v := vals["rate"]; v != "" {
+		if apiKey.Rate, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, errors.Wrapf(err, "parse rate of API key %q", apiKey.Name)
+		}
+	}
+	if v := vals["burst"]; v != "" {
+		if apiKey.Burst, err = strconv.Atoi(v); err != nil {
+			return nil, errors.Wrapf(err, "parse burst of API key %q", apiKey.Name)
+		}
+	}
+	if v := vals["quota"]; v != "" {
+		if apiKey.Quota, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "parse quota of API key %q", apiKey.Name)
+		}
+	}
+	apiKey.Disabled = vals["disabled"] == "1" || vals["disabled"] == "true"
+	apiKey.Admin = vals["admin"] == "1" || vals["admin"] == "true"
+
+	return &apiKey, nil
+}
+
+// usageTTL is how long daily usage counters are kept, in seconds
+const usageTTL = 35 * 24 * 60 * 60
+
+// takeScript takes a token from client bucket "ratelimit:<client>" refilled with rate tokens
+// per second up to burst, if daily quota stored in "usage:<client>:<day>" is not exhausted.
+// Usage hash counts "allowed" and "limited" requests.
+// Returns allowed flag, milliseconds to wait for a token (-1 if quota is exhausted),
+// tokens left and requests allowed today.
+var takeScript = radix.NewEvalScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local quota = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+
+local function limited(wait, used)
+	redis.call("HINCRBY", KEYS[2], "limited", 1)
+	redis.call("EXPIRE", KEYS[2], ttl)
+	return {0, wait, 0, used}
+end
+
+local used = tonumber(redis.call("HGET", KEYS[2], "allowed") or "0")
+if quota > 0 and used >= quota then
+	return limited(-1, used)
+end
+
+local tokens = burst
+if rate > 0 then
+	local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+	tokens = tonumber(state[1]) or burst
+	local ts = tonumber(state[2]) or now
+	tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+	if tokens < 1 then
+		return limited(math.ceil((1 - tokens) / rate * 1000), used)
+	end
+	tokens = tokens - 1
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+	redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+end
+
+used = redis.call("HINCRBY", KEYS[2], "allowed", 1)
+redis.call("EXPIRE", KEYS[2], ttl)
+return {1, 0, math.floor(tokens), used}
+`)
+
+// Allowance is result of taking request from client limits
+type Allowance struct {
+	Allowed    bool
+	RetryAfter time.Duration // when request is not allowed
+	Remaining  int           // requests left in the bucket
+	Used       int64         // requests allowed today
+}
+
+// Take counts request of the client (e.g. "key:<API key>" or "ip:<address>")
+// against its token bucket and daily quota, zero rate and quota are not applied
+func (c *Client) Take(client string, rate float64, burst int, quota int64) (Allowance, error) {
+	now := time.Now().UTC()
+	day := now.Format("2006-01-02")
+
+	var res []int64
+	err := c.client.Do(c.ctx, namedAction{
+		Action: takeScript.FlatCmd(
+			&res,
+			[]string{"ratelimit:" + client, "usage:" + client + ":" + day},
+			rate, burst, quota, now.UnixMilli(), usageTTL,
+		),
+		command: "EVALSHA",
+	})
+	if err != nil {
+		return Allowance{}, errors.Wrapf(err, "take request of %q", client)
+	}
+	if len(res) != 4 {
+		return Allowance{}, errors.Errorf("unexpected rate limit result %v", res)
+	}
+
+	a := Allowance{
+		Allowed:    res[0] == 1,
+		RetryAfter: time.Duration(res[1]) * time.Millisecond,
+		Remaining:  int(res[2]),
+		Used:       res[3],
+	}
+	if res[1] < 0 {
+		// quota is renewed at midnight UTC
+		a.RetryAfter = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+	}
+	return a, nil
+}
+
+// GetUsage returns numbers of "allowed" and "limited" requests of the client on the day (UTC)
+func (c *Client) GetUsage(client string, day time.Time) (map[string]int64, error) {
+	var vals map[string]int64
+	key := "usage:" + client + ":" + day.UTC().Format("2006-01-02")
+	if err := c.client.Do(c.ctx, cmd(&vals, "HGETALL", key)); err != nil {
+		return nil, errors.Wrapf(err, "get usage of %q", client)
+	}
+	return vals, nil
+}
@@ -0,0 +1,23 @@
+package structs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// APIKey represents client of the API, zero limits are not applied
+type APIKey struct {
+	Key      string  `json:"key"`
+	Name     string  `json:"name"`
+	Rate     float64 `json:"rate"`  // requests per second
+	Burst    int     `json:"burst"` // requests allowed at once, at least rate
+	Quota    int64   `json:"quota"` // requests per day (UTC)
+	Disabled bool    `json:"disabled"`
+	Admin    bool    `json:"admin"` // may manage resources registered with other keys
+}
+
+// ID identifies API key without revealing it
+func (k APIKey) ID() string {
+	sum := sha256.Sum256([]byte(k.Key))
+	return hex.EncodeToString(sum[:8])
+}
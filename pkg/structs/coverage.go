@@ -0,0 +1,8 @@
+package structs
+
+// Coverage represents system service area computed from station and vehicle positions
+type Coverage struct {
+	BBox   [4]float64  `json:"bbox"`   // min lon, min lat, max lon, max lat
+	Center []float64   `json:"center"` // lon, lat
+	Hull   [][]float64 `json:"hull"`   // closed ring of lon, lat positions
+}
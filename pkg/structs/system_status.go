@@ -0,0 +1,19 @@
+package structs
+
+import "time"
+
+// Health of the system, as checked by writer
+const (
+	SystemHealthOK       = "ok"       // all checked feeds were loaded
+	SystemHealthDegraded = "degraded" // auto-discovery was loaded, but some feeds were not
+	SystemHealthDown     = "down"     // auto-discovery could not be loaded
+)
+
+// SystemStatus represents result of the last system check by writer
+type SystemStatus struct {
+	Version       string    `json:"version"` // GBFS version of auto-discovery feed
+	Health        string    `json:"health"`
+	Error         string    `json:"error,omitempty"`
+	StationsCount int       `json:"stationsCount"`
+	CheckedAt     time.Time `json:"checkedAt"`
+}
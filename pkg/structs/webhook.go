@@ -0,0 +1,9 @@
+package structs
+
+// Webhook represents subscriber of system alerts changes
+type Webhook struct {
+	SystemID string `json:"systemId"`
+	URL      string `json:"url"`
+	Secret   string `json:"secret"`
+	Owner    string `json:"owner,omitempty"` // ID of API key registered the webhook
+}
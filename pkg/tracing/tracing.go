@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chuhlomin/gbfs-tools/pkg/metrics"
+)
+
+const tracerName = "github.com/chuhlomin/gbfs-tools"
+
+// Setup exports spans of the service to OTLP collector at endpoint (e.g. "localhost:4318")
+// over HTTP, returned function flushes remaining spans.
+// Without Setup spans are not recorded.
+func Setup(ctx context.Context, service, endpoint string, insecure bool) (func(context.Context) error, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "create OTLP exporter")
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", service)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create resource")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// Start starts span as a child of span in context
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End marks span as failed if err is not nil and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract returns context with remote span from traceparent header of the request
+func Extract(ctx context.Context, r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+}
+
+// StartFetch starts span of the system feed fetch,
+// returned function ends it, records fetch metrics and logs it
+func StartFetch(ctx context.Context, systemID, feed, url string) func(error) {
+	ctx, span := Start(
+		ctx,
+		"gbfs "+feed,
+		attribute.String("gbfs.system_id", systemID),
+		attribute.String("gbfs.feed", feed),
+		attribute.String("http.url", url),
+	)
+	start := time.Now()
+
+	return func(err error) {
+		duration := time.Since(start)
+		metrics.ObserveUpstream(systemID, feed, duration, err)
+		End(span, err)
+
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch feed", "system", systemID, "feed", feed, "url", url, "duration", duration, "error", err)
+			return
+		}
+		slog.DebugContext(ctx, "Fetched feed", "system", systemID, "feed", feed, "url", url, "duration", duration)
+	}
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// sharedAddressSpace is carrier-grade NAT range (RFC 6598), not reachable from the internet
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// forbiddenAddressError is returned for webhooks pointing to internal addresses
+type forbiddenAddressError struct {
+	address string
+}
+
+func (e forbiddenAddressError) Error() string {
+	return fmt.Sprintf("address %s is not allowed", e.address)
+}
+
+// ForbiddenIP checks if IP is loopback, private, link-local (including cloud metadata service),
+// unspecified or multicast address, webhooks are not delivered to such addresses
+func ForbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// CheckURL parses webhook URL and checks that it is absolute HTTP(S) URL
+// with host resolving only to public addresses (unless allowPrivate is set)
+func CheckURL(ctx context.Context, rawURL string, allowPrivate bool) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, errors.Errorf("invalid webhook URL %q, expected absolute HTTP(S) URL", rawURL)
+	}
+
+	if allowPrivate {
+		return u, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolve %q", u.Hostname())
+	}
+
+	for _, addr := range addrs {
+		if ForbiddenIP(addr.IP) {
+			return nil, forbiddenAddressError{address: addr.IP.String()}
+		}
+	}
+
+	return u, nil
+}
+
+// dialControl rejects connections to forbidden addresses,
+// so DNS changes after registration and redirects can't reach internal hosts
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ForbiddenIP(ip) {
+		return forbiddenAddressError{address: host}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/chuhlomin/gbfs-go"
+)
+
+// EventType describes what happened with the alert
+type EventType string
+
+const EventAlertCreated EventType = "alert.created"
+const EventAlertUpdated EventType = "alert.updated"
+const EventAlertResolved EventType = "alert.resolved"
+
+// Event is a payload delivered to subscribers
+type Event struct {
+	ID        string    `json:"id"`
+	Type      EventType `json:"type"`
+	SystemID  string    `json:"systemId"`
+	Alert     Alert     `json:"alert"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Alert mirrors GBFS alert, omitting empty timestamps
+type Alert struct {
+	ID          string      `json:"alert_id"`
+	Type        string      `json:"type"`
+	Times       []AlertTime `json:"times,omitempty"`
+	StationIDs  []string    `json:"station_ids,omitempty"`
+	RegionIDs   []string    `json:"region_ids,omitempty"`
+	URL         string      `json:"url,omitempty"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description,omitempty"`
+	LastUpdated int64       `json:"last_updated,omitempty"`
+}
+
+type AlertTime struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end,omitempty"`
+}
+
+// NewAlert converts GBFS alert
+func NewAlert(a gbfs.Alert) Alert {
+	result := Alert{
+		ID:          string(a.ID),
+		Type:        string(a.Type),
+		StationIDs:  a.StationIDs,
+		RegionIDs:   a.RegionIDs,
+		URL:         a.URL,
+		Summary:     a.Summary,
+		Description: a.Description,
+	}
+
+	for _, t := range a.Times {
+		at := AlertTime{Start: t.Start.Unix()}
+		if !time.Time(t.End).IsZero() {
+			at.End = t.End.Unix()
+		}
+		result.Times = append(result.Times, at)
+	}
+
+	if !time.Time(a.LastUpdated).IsZero() {
+		result.LastUpdated = a.LastUpdated.Unix()
+	}
+
+	return result
+}
+
+// NewEvent creates new event with random ID
+func NewEvent(eventType EventType, systemID string, alert Alert) Event {
+	return Event{
+		ID:        RandomString(16),
+		Type:      eventType,
+		SystemID:  systemID,
+		Alert:     alert,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// RandomString returns hex-encoded string of n random bytes
+func RandomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
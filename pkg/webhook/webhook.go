@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-GBFS-Event"
+	HeaderID        = "X-GBFS-Delivery"
+	HeaderTimestamp = "X-GBFS-Timestamp"
+	HeaderSignature = "X-GBFS-Signature"
+)
+
+// Sender delivers events to subscribers, retrying failed deliveries
+// with exponential backoff
+type Sender struct {
+	client      *http.Client
+	userAgent   string
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewSender creates new Sender,
+// events are not delivered to internal addresses unless allowPrivate is set
+func NewSender(userAgent string, timeout time.Duration, maxAttempts int, backoff time.Duration, allowPrivate bool) *Sender {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = dialControl
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		userAgent:   userAgent,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		maxBackoff:  5 * time.Minute,
+	}
+}
+
+// Send posts event to URL, payload is signed with secret.
+// Returns error only after all attempts failed or context is done.
+func (s *Sender) Send(ctx context.Context, url, secret string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "marshal event")
+	}
+
+	delay := s.backoff
+	for attempt := 1; ; attempt++ {
+		err = s.post(ctx, url, secret, event, body)
+		if err == nil {
+			return nil
+		}
+
+		if _, ok := err.(permanentError); ok || attempt >= s.maxAttempts {
+			return errors.Wrapf(err, "deliver %s to %q after %d attempt(s)", event.ID, url, attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > s.maxBackoff {
+			delay = s.maxBackoff
+		}
+	}
+}
+
+// permanentError is returned when retry won't help,
+// like when subscriber responds with 4xx status code
+type permanentError struct {
+	error
+}
+
+func (s *Sender) post(ctx context.Context, url, secret string, event Event, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{errors.Wrap(err, "create new request")}
+	}
+	req = req.WithContext(ctx)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderID, event.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if errors.As(err, new(forbiddenAddressError)) {
+			return permanentError{errors.Wrap(err, "send request")}
+		}
+		return errors.Wrap(err, "send request")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	default:
+		return permanentError{fmt.Errorf("unexpected status code %d", resp.StatusCode)}
+	}
+}
+
+// Sign returns hex-encoded HMAC-SHA256 of timestamp and body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks signature header value of the delivery
+func Verify(secret, timestamp, signature string, body []byte) bool {
+	expected := "sha256=" + Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// CheckTimestamp returns error if timestamp header value differs from current time
+// by more than tolerance, so captured deliveries can't be replayed later.
+// Every delivery attempt is signed with a new timestamp.
+func CheckTimestamp(timestamp string, tolerance time.Duration) error {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+
+	age := time.Since(time.Unix(sec, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp %q is older or newer than %v", timestamp, tolerance)
+	}
+	return nil
+}